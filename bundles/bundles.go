@@ -0,0 +1,289 @@
+// Package bundles stores the curated bundles of packages (e.g. "Web
+// developer") that users can add to their apps in one call. Admins manage
+// them through the API or load them from a YAML file with Seed.
+package bundles
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"setupforme/models"
+	"setupforme/utils"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned for a slug no bundle has
+	ErrNotFound = errors.New("bundle not found")
+	// ErrSlugTaken is returned when another bundle already has the slug
+	ErrSlugTaken = errors.New("bundle slug already exists")
+)
+
+// Bounds for a bundle's slug and packages
+const (
+	maxSlugLength = 64
+	maxPackages   = 100
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Validate trims a bundle request and fills in package names. It returns a
+// client-facing message, or "" when the bundle is valid.
+func Validate(req *models.BundleRequest) string {
+	req.Slug = strings.TrimSpace(req.Slug)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+
+	if !slugPattern.MatchString(req.Slug) || len(req.Slug) > maxSlugLength {
+		return "slug must be 1-64 lowercase letters, digits and single dashes (e.g. web-developer)"
+	}
+	if req.Name == "" || len(req.Name) > 255 {
+		return "name is required and must be at most 255 characters"
+	}
+	if len(req.Packages) == 0 || len(req.Packages) > maxPackages {
+		return fmt.Sprintf("a bundle needs 1 to %d packages", maxPackages)
+	}
+
+	seen := make(map[string]bool, len(req.Packages))
+	for i := range req.Packages {
+		pkg := &req.Packages[i]
+		pkg.WingetID = strings.TrimSpace(pkg.WingetID)
+		pkg.Name = strings.TrimSpace(pkg.Name)
+		pkg.Args = strings.TrimSpace(pkg.Args)
+
+		if !utils.IsValidWingetID(pkg.WingetID) {
+			return fmt.Sprintf("packages[%d]: invalid winget_id %q", i, pkg.WingetID)
+		}
+		key := strings.ToLower(pkg.WingetID)
+		if seen[key] {
+			return fmt.Sprintf("packages[%d]: %s is listed twice", i, pkg.WingetID)
+		}
+		seen[key] = true
+
+		if pkg.Name == "" {
+			pkg.Name = pkg.WingetID
+		}
+	}
+
+	return ""
+}
+
+// CheckPackages looks up the packages of a validated bundle in the package
+// source and stores each package's own spelling of its ID. It returns a
+// client-facing message for the first package the source does not know, or
+// "". Packages that cannot be looked up right now are accepted unverified, as
+// are Microsoft Store IDs, which the source does not cover.
+func CheckPackages(source utils.PackageSource, req *models.BundleRequest) string {
+	for i := range req.Packages {
+		pkg := &req.Packages[i]
+		if utils.SourceForID(pkg.WingetID) == utils.SourceMSStore {
+			continue
+		}
+
+		found, err := source.Details(pkg.WingetID)
+		switch {
+		case err == nil:
+			if pkg.Name == pkg.WingetID {
+				pkg.Name = found.Id
+			}
+			pkg.WingetID = found.Id
+		case errors.Is(err, utils.ErrPackageNotFound):
+			return fmt.Sprintf("packages[%d]: unknown winget_id %s", i, pkg.WingetID)
+		default:
+			log.Printf("bundles: %s accepted without checking: %v", pkg.WingetID, err)
+		}
+	}
+	return ""
+}
+
+// List returns all bundles with their packages, ordered by name
+func List(db *sql.DB) ([]models.Bundle, error) {
+	rows, err := db.Query(`
+		SELECT id, slug, name, description, created_at, updated_at
+		FROM bundles
+		ORDER BY name, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	bundles := []models.Bundle{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var b models.Bundle
+		if err := rows.Scan(&b.ID, &b.Slug, &b.Name, &b.Description, &b.CreatedAt, &b.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		b.Packages = []models.BundlePackage{}
+		index[b.ID] = len(bundles)
+		bundles = append(bundles, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query("SELECT bundle_id, winget_id, name, args FROM bundle_packages ORDER BY bundle_id, position")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bundleID int
+		var pkg models.BundlePackage
+		if err := rows.Scan(&bundleID, &pkg.WingetID, &pkg.Name, &pkg.Args); err != nil {
+			return nil, err
+		}
+		if i, ok := index[bundleID]; ok {
+			bundles[i].Packages = append(bundles[i].Packages, pkg)
+		}
+	}
+
+	return bundles, rows.Err()
+}
+
+// Get returns the bundle with the given slug
+func Get(db *sql.DB, slug string) (models.Bundle, error) {
+	var b models.Bundle
+	err := db.QueryRow(`
+		SELECT id, slug, name, description, created_at, updated_at
+		FROM bundles WHERE slug = $1
+	`, slug).Scan(&b.ID, &b.Slug, &b.Name, &b.Description, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return b, ErrNotFound
+	}
+	if err != nil {
+		return b, err
+	}
+
+	rows, err := db.Query("SELECT winget_id, name, args FROM bundle_packages WHERE bundle_id = $1 ORDER BY position", b.ID)
+	if err != nil {
+		return b, err
+	}
+	defer rows.Close()
+
+	b.Packages = []models.BundlePackage{}
+	for rows.Next() {
+		var pkg models.BundlePackage
+		if err := rows.Scan(&pkg.WingetID, &pkg.Name, &pkg.Args); err != nil {
+			return b, err
+		}
+		b.Packages = append(b.Packages, pkg)
+	}
+
+	return b, rows.Err()
+}
+
+// Create stores a new bundle from a validated request
+func Create(db *sql.DB, req models.BundleRequest) (models.Bundle, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Bundle{}, err
+	}
+	defer tx.Rollback()
+
+	b := bundleFromRequest(req)
+	err = tx.QueryRow(`
+		INSERT INTO bundles (slug, name, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`, req.Slug, req.Name, req.Description).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if err != nil {
+		return b, slugError(err)
+	}
+
+	if err := savePackages(tx, b.ID, req.Packages); err != nil {
+		return b, err
+	}
+
+	return b, tx.Commit()
+}
+
+// Update replaces the bundle with the given slug by a validated request,
+// which may also change the slug
+func Update(db *sql.DB, slug string, req models.BundleRequest) (models.Bundle, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Bundle{}, err
+	}
+	defer tx.Rollback()
+
+	b := bundleFromRequest(req)
+	err = tx.QueryRow(`
+		UPDATE bundles SET slug = $1, name = $2, description = $3, updated_at = NOW()
+		WHERE slug = $4
+		RETURNING id, created_at, updated_at
+	`, req.Slug, req.Name, req.Description, slug).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return b, ErrNotFound
+	}
+	if err != nil {
+		return b, slugError(err)
+	}
+
+	if err := savePackages(tx, b.ID, req.Packages); err != nil {
+		return b, err
+	}
+
+	return b, tx.Commit()
+}
+
+// Delete removes the bundle with the given slug
+func Delete(db *sql.DB, slug string) error {
+	result, err := db.Exec("DELETE FROM bundles WHERE slug = $1", slug)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// savePackages replaces the packages of a bundle, keeping their order
+func savePackages(tx *sql.Tx, bundleID int, packages []models.BundlePackage) error {
+	if _, err := tx.Exec("DELETE FROM bundle_packages WHERE bundle_id = $1", bundleID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO bundle_packages (bundle_id, position, winget_id, name, args)
+		VALUES ($1, $2, $3, $4, $5)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, pkg := range packages {
+		if _, err := stmt.Exec(bundleID, i, pkg.WingetID, pkg.Name, pkg.Args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func bundleFromRequest(req models.BundleRequest) models.Bundle {
+	return models.Bundle{
+		Slug:        req.Slug,
+		Name:        req.Name,
+		Description: req.Description,
+		Packages:    req.Packages,
+	}
+}
+
+// slugError maps a unique violation on the slug to ErrSlugTaken
+func slugError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrSlugTaken
+	}
+	return err
+}
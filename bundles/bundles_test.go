@@ -0,0 +1,35 @@
+package bundles_test
+
+import (
+	"strings"
+	"testing"
+
+	"setupforme/bundles"
+	"setupforme/models"
+	"setupforme/utils/wingetfake"
+)
+
+func TestCheckPackages(t *testing.T) {
+	srv := wingetfake.NewServer(wingetfake.Package("Git.Git", "Git", "The Git Team", "2.44.0"))
+	defer srv.Close()
+	source := srv.Source()
+
+	req := models.BundleRequest{Slug: "tools", Name: "Tools", Packages: []models.BundlePackage{
+		{WingetID: "git.git"},
+		{WingetID: "9NBLGGH4NNS1", Name: "App Installer"},
+	}}
+	if msg := bundles.Validate(&req); msg != "" {
+		t.Fatal(msg)
+	}
+	if msg := bundles.CheckPackages(source, &req); msg != "" {
+		t.Fatalf("CheckPackages = %q, want no error", msg)
+	}
+	if got := req.Packages[0]; got.WingetID != "Git.Git" || got.Name != "Git.Git" {
+		t.Errorf("package 0 = %+v, want the source's spelling Git.Git", got)
+	}
+
+	req.Packages = append(req.Packages, models.BundlePackage{WingetID: "Nobody.Nothing", Name: "Nothing"})
+	if msg := bundles.CheckPackages(source, &req); !strings.Contains(msg, "Nobody.Nothing") {
+		t.Errorf("CheckPackages with an unknown package = %q, want it named", msg)
+	}
+}
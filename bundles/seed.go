@@ -0,0 +1,122 @@
+package bundles
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"setupforme/models"
+	"setupforme/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// seedFile is the YAML layout read by Seed:
+//
+//	bundles:
+//	  - slug: web-developer
+//	    name: Web developer
+//	    description: Editor, runtimes and tools for web development
+//	    packages:
+//	      - winget_id: Microsoft.VisualStudioCode
+//	        name: Visual Studio Code
+//	        args: --override "/VERYSILENT /MERGETASKS=!runcode"
+type seedFile struct {
+	Bundles []seedBundle `yaml:"bundles"`
+}
+
+type seedBundle struct {
+	Slug        string        `yaml:"slug"`
+	Name        string        `yaml:"name"`
+	Description string        `yaml:"description"`
+	Packages    []seedPackage `yaml:"packages"`
+}
+
+type seedPackage struct {
+	WingetID string `yaml:"winget_id"`
+	Name     string `yaml:"name"`
+	Args     string `yaml:"args"`
+}
+
+// Stats counts the bundles a seed created or replaced and their packages
+type Stats struct {
+	Created  int
+	Updated  int
+	Packages int
+}
+
+// Seed loads the bundles of a YAML file. Bundles are matched by slug: new
+// ones are created and existing ones replaced, while bundles missing from the
+// file are kept. Nothing is written unless every bundle in the file is valid
+// and its packages pass CheckPackages against source.
+func Seed(db *sql.DB, source utils.PackageSource, path string) (Stats, error) {
+	var stats Stats
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stats, err
+	}
+	var file seedFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return stats, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file.Bundles) == 0 {
+		return stats, fmt.Errorf("%s holds no bundles", path)
+	}
+
+	reqs := make([]models.BundleRequest, len(file.Bundles))
+	slugs := make(map[string]bool, len(file.Bundles))
+	for i, sb := range file.Bundles {
+		req := models.BundleRequest{Slug: sb.Slug, Name: sb.Name, Description: sb.Description}
+		for _, sp := range sb.Packages {
+			req.Packages = append(req.Packages, models.BundlePackage{WingetID: sp.WingetID, Name: sp.Name, Args: sp.Args})
+		}
+		if msg := Validate(&req); msg != "" {
+			return stats, fmt.Errorf("bundle %d (%s): %s", i+1, sb.Slug, msg)
+		}
+		if msg := CheckPackages(source, &req); msg != "" {
+			return stats, fmt.Errorf("bundle %d (%s): %s", i+1, sb.Slug, msg)
+		}
+		if slugs[req.Slug] {
+			return stats, fmt.Errorf("bundle %d: slug %s is used twice", i+1, req.Slug)
+		}
+		slugs[req.Slug] = true
+		reqs[i] = req
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	for _, req := range reqs {
+		var bundleID int
+		err := tx.QueryRow("SELECT id FROM bundles WHERE slug = $1", req.Slug).Scan(&bundleID)
+		switch {
+		case err == sql.ErrNoRows:
+			err = tx.QueryRow(`
+				INSERT INTO bundles (slug, name, description)
+				VALUES ($1, $2, $3)
+				RETURNING id
+			`, req.Slug, req.Name, req.Description).Scan(&bundleID)
+			stats.Created++
+		case err == nil:
+			_, err = tx.Exec(`
+				UPDATE bundles SET name = $1, description = $2, updated_at = NOW()
+				WHERE id = $3
+			`, req.Name, req.Description, bundleID)
+			stats.Updated++
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if err := savePackages(tx, bundleID, req.Packages); err != nil {
+			return stats, err
+		}
+		stats.Packages += len(req.Packages)
+	}
+
+	return stats, tx.Commit()
+}
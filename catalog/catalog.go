@@ -0,0 +1,158 @@
+// Package catalog keeps an offline index of winget packages in Postgres. It is
+// imported from a local checkout of the microsoft/winget-pkgs manifests and
+// searched with full-text and trigram matching instead of calling winget.run.
+package catalog
+
+import (
+	"database/sql"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"setupforme/utils"
+)
+
+// Stats counts what an import wrote to the catalog
+type Stats struct {
+	Packages   int
+	Versions   int
+	Installers int
+	Skipped    int // manifest directories that could not be parsed
+}
+
+// catalogPackage gathers every version of one package found in the checkout
+type catalogPackage struct {
+	latest   *packageVersion
+	versions map[string]*packageVersion
+}
+
+// Import walks a winget-pkgs checkout and replaces the catalog with the
+// packages it holds. root may be the repository itself or its manifests
+// directory. The catalog is swapped in a single transaction, so searches keep
+// using the previous index until the import is committed.
+func Import(db *sql.DB, root string) (Stats, error) {
+	var stats Stats
+
+	if info, err := os.Stat(filepath.Join(root, "manifests")); err == nil && info.IsDir() {
+		root = filepath.Join(root, "manifests")
+	}
+
+	packages, skipped, err := scanManifests(root)
+	stats.Skipped = skipped
+	if err != nil {
+		return stats, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	// Versions and installers are removed with their package
+	if _, err := tx.Exec("DELETE FROM catalog_packages"); err != nil {
+		return stats, err
+	}
+
+	insertPackage, err := tx.Prepare(`
+		INSERT INTO catalog_packages (id, name, publisher, description, homepage, license, moniker, tags, latest_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)
+	if err != nil {
+		return stats, err
+	}
+	insertVersion, err := tx.Prepare("INSERT INTO catalog_versions (package_id, version) VALUES ($1, $2)")
+	if err != nil {
+		return stats, err
+	}
+	insertInstaller, err := tx.Prepare(`
+		INSERT INTO catalog_installers (package_id, version, architecture, installer_type, scope, url, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, pkg := range packages {
+		latest := pkg.latest
+		if _, err := insertPackage.Exec(latest.ID, latest.Name, latest.Publisher, latest.Description,
+			latest.Homepage, latest.License, latest.Moniker, strings.Join(latest.Tags, " "), latest.Version); err != nil {
+			return stats, err
+		}
+		stats.Packages++
+
+		for _, pv := range pkg.versions {
+			if _, err := insertVersion.Exec(latest.ID, pv.Version); err != nil {
+				return stats, err
+			}
+			stats.Versions++
+
+			for _, in := range pv.Installers {
+				if _, err := insertInstaller.Exec(latest.ID, pv.Version, in.Architecture,
+					strings.ToLower(in.InstallerType), strings.ToLower(in.Scope), in.URL, in.SHA256); err != nil {
+					return stats, err
+				}
+				stats.Installers++
+			}
+		}
+	}
+
+	return stats, tx.Commit()
+}
+
+// scanManifests reads every version directory under root and groups the
+// versions by package, picking each package's latest version. It returns how
+// many manifest directories could not be parsed.
+func scanManifests(root string) (packages map[string]*catalogPackage, skipped int, err error) {
+	packages = map[string]*catalogPackage{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		var files []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".yaml") {
+				files = append(files, entry.Name())
+			}
+		}
+		if len(files) == 0 {
+			return nil
+		}
+
+		pv, err := readVersionDir(path, files)
+		if err != nil {
+			log.Printf("catalog: skipping %s: %v", path, err)
+			skipped++
+			return nil
+		}
+		if pv == nil {
+			log.Printf("catalog: skipping %s: no package manifest", path)
+			skipped++
+			return nil
+		}
+
+		key := strings.ToLower(pv.ID)
+		pkg := packages[key]
+		if pkg == nil {
+			pkg = &catalogPackage{versions: map[string]*packageVersion{}}
+			packages[key] = pkg
+		}
+		pkg.versions[pv.Version] = pv
+		if pkg.latest == nil || utils.CompareVersions(pv.Version, pkg.latest.Version) > 0 {
+			pkg.latest = pv
+		}
+		return nil
+	})
+	return packages, skipped, err
+}
@@ -0,0 +1,145 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifest holds the fields we use from any winget manifest file. A version
+// directory splits them across a version, an installer and locale manifests,
+// or puts them all in one singleton manifest.
+type manifest struct {
+	PackageIdentifier string              `yaml:"PackageIdentifier"`
+	PackageVersion    string              `yaml:"PackageVersion"`
+	ManifestType      string              `yaml:"ManifestType"`
+	PackageLocale     string              `yaml:"PackageLocale"`
+	PackageName       string              `yaml:"PackageName"`
+	Publisher         string              `yaml:"Publisher"`
+	ShortDescription  string              `yaml:"ShortDescription"`
+	Description       string              `yaml:"Description"`
+	License           string              `yaml:"License"`
+	PackageURL        string              `yaml:"PackageUrl"`
+	PublisherURL      string              `yaml:"PublisherUrl"`
+	Moniker           string              `yaml:"Moniker"`
+	Tags              []string            `yaml:"Tags"`
+	InstallerType     string              `yaml:"InstallerType"`
+	Scope             string              `yaml:"Scope"`
+	Installers        []installerManifest `yaml:"Installers"`
+}
+
+type installerManifest struct {
+	Architecture    string `yaml:"Architecture"`
+	InstallerType   string `yaml:"InstallerType"`
+	Scope           string `yaml:"Scope"`
+	InstallerURL    string `yaml:"InstallerUrl"`
+	InstallerSha256 string `yaml:"InstallerSha256"`
+}
+
+// Installer is one installer published for a package version
+type Installer struct {
+	Architecture  string
+	InstallerType string
+	Scope         string
+	URL           string
+	SHA256        string
+}
+
+// packageVersion is one version directory with its manifests merged
+type packageVersion struct {
+	ID          string
+	Version     string
+	Name        string
+	Publisher   string
+	Description string
+	Homepage    string
+	License     string
+	Moniker     string
+	Tags        []string
+	Installers  []Installer
+}
+
+// readVersionDir parses the manifests of one version directory. It returns
+// nil when the directory holds no manifest of a package version.
+func readVersionDir(dir string, files []string) (*packageVersion, error) {
+	var locale, fallbackLocale, installers *manifest
+	var pv packageVersion
+
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		var m manifest
+		if err := yaml.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		if m.PackageIdentifier == "" || m.PackageVersion == "" {
+			continue
+		}
+		pv.ID, pv.Version = m.PackageIdentifier, m.PackageVersion
+
+		switch m.ManifestType {
+		case "singleton":
+			locale, installers = &m, &m
+		case "defaultLocale":
+			locale = &m
+		case "locale":
+			if fallbackLocale == nil || strings.EqualFold(m.PackageLocale, "en-US") {
+				fallbackLocale = &m
+			}
+		case "installer":
+			installers = &m
+		}
+	}
+
+	if pv.ID == "" {
+		return nil, nil
+	}
+	if locale == nil {
+		locale = fallbackLocale
+	}
+
+	if locale != nil {
+		pv.Name = locale.PackageName
+		pv.Publisher = locale.Publisher
+		pv.Description = locale.ShortDescription
+		if pv.Description == "" {
+			pv.Description = locale.Description
+		}
+		pv.Homepage = locale.PackageURL
+		if pv.Homepage == "" {
+			pv.Homepage = locale.PublisherURL
+		}
+		pv.License = locale.License
+		pv.Moniker = locale.Moniker
+		pv.Tags = locale.Tags
+	}
+	if pv.Name == "" {
+		pv.Name = pv.ID
+	}
+
+	if installers != nil {
+		// Installer entries inherit the type and scope set at the top level
+		for _, in := range installers.Installers {
+			installer := Installer{
+				Architecture:  in.Architecture,
+				InstallerType: in.InstallerType,
+				Scope:         in.Scope,
+				URL:           in.InstallerURL,
+				SHA256:        strings.ToLower(in.InstallerSha256),
+			}
+			if installer.InstallerType == "" {
+				installer.InstallerType = installers.InstallerType
+			}
+			if installer.Scope == "" {
+				installer.Scope = installers.Scope
+			}
+			pv.Installers = append(pv.Installers, installer)
+		}
+	}
+
+	return &pv, nil
+}
@@ -0,0 +1,157 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeManifests creates the files under root, creating directories as needed
+func writeManifests(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// splitManifests returns the manifests of one Git version in the split layout
+func splitManifests(version string) map[string]string {
+	dir := "g/Git/Git/" + version + "/"
+	return map[string]string{
+		dir + "Git.Git.yaml": `PackageIdentifier: Git.Git
+PackageVersion: ` + version + `
+DefaultLocale: en-US
+ManifestType: version
+`,
+		dir + "Git.Git.locale.en-US.yaml": `PackageIdentifier: Git.Git
+PackageVersion: ` + version + `
+PackageLocale: en-US
+PackageName: Git
+Publisher: The Git Development Community
+ShortDescription: Git for Windows
+License: GPL-2.0
+PackageUrl: https://gitforwindows.org
+Tags: [git, vcs]
+ManifestType: defaultLocale
+`,
+		dir + "Git.Git.locale.de-DE.yaml": `PackageIdentifier: Git.Git
+PackageVersion: ` + version + `
+PackageLocale: de-DE
+PackageName: Git (Deutsch)
+ManifestType: locale
+`,
+		dir + "Git.Git.installer.yaml": `PackageIdentifier: Git.Git
+PackageVersion: ` + version + `
+InstallerType: inno
+Scope: machine
+Installers:
+  - Architecture: x64
+    InstallerUrl: https://example.com/Git-` + version + `-64-bit.exe
+    InstallerSha256: ABCDEF
+  - Architecture: x86
+    InstallerType: exe
+    Scope: user
+    InstallerUrl: https://example.com/Git-` + version + `-32-bit.exe
+ManifestType: installer
+`,
+	}
+}
+
+func TestScanManifests(t *testing.T) {
+	root := t.TempDir()
+	// 2.10.0 is the latest version, though it sorts before 2.9.0 as text
+	writeManifests(t, root, splitManifests("2.9.0"))
+	writeManifests(t, root, splitManifests("2.10.0"))
+	writeManifests(t, root, map[string]string{
+		"7/7zip/7zip/23.01/7zip.7zip.yaml": `PackageIdentifier: 7zip.7zip
+PackageVersion: "23.01"
+PackageLocale: en-US
+PackageName: 7-Zip
+Publisher: Igor Pavlov
+Description: Free file archiver
+PublisherUrl: https://www.7-zip.org
+InstallerType: wix
+Installers:
+  - Architecture: x64
+    InstallerUrl: https://example.com/7z2301-x64.msi
+ManifestType: singleton
+`,
+		"b/Broken/1.0/Broken.yaml": "PackageIdentifier: [unclosed\n",
+		".github/ignored.yaml":     "PackageIdentifier: Hidden.Tool\nPackageVersion: 1.0\n",
+	})
+
+	packages, skipped, err := scanManifests(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1 (the broken manifest)", skipped)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("found %d packages, want Git.Git and 7zip.7zip", len(packages))
+	}
+
+	git := packages["git.git"]
+	if git == nil || len(git.versions) != 2 {
+		t.Fatalf("git.git = %+v, want two versions", git)
+	}
+	want := &packageVersion{
+		ID:          "Git.Git",
+		Version:     "2.10.0",
+		Name:        "Git",
+		Publisher:   "The Git Development Community",
+		Description: "Git for Windows",
+		Homepage:    "https://gitforwindows.org",
+		License:     "GPL-2.0",
+		Tags:        []string{"git", "vcs"},
+		Installers: []Installer{
+			{Architecture: "x64", InstallerType: "inno", Scope: "machine", URL: "https://example.com/Git-2.10.0-64-bit.exe", SHA256: "abcdef"},
+			{Architecture: "x86", InstallerType: "exe", Scope: "user", URL: "https://example.com/Git-2.10.0-32-bit.exe"},
+		},
+	}
+	if !reflect.DeepEqual(git.latest, want) {
+		t.Errorf("latest Git version = %+v\nwant %+v", git.latest, want)
+	}
+
+	sevenZip := packages["7zip.7zip"]
+	if sevenZip == nil {
+		t.Fatal("singleton manifest was not read")
+	}
+	want = &packageVersion{
+		ID:          "7zip.7zip",
+		Version:     "23.01",
+		Name:        "7-Zip",
+		Publisher:   "Igor Pavlov",
+		Description: "Free file archiver",
+		Homepage:    "https://www.7-zip.org",
+		Installers:  []Installer{{Architecture: "x64", InstallerType: "wix", URL: "https://example.com/7z2301-x64.msi"}},
+	}
+	if !reflect.DeepEqual(sevenZip.latest, want) {
+		t.Errorf("7-Zip = %+v\nwant %+v", sevenZip.latest, want)
+	}
+}
+
+// Without a defaultLocale manifest, the en-US locale is used
+func TestReadVersionDirLocaleFallback(t *testing.T) {
+	dir := t.TempDir()
+	writeManifests(t, dir, map[string]string{
+		"Tool.locale.de-DE.yaml": "PackageIdentifier: Some.Tool\nPackageVersion: 1.0\nPackageName: Werkzeug\nManifestType: locale\n",
+		"Tool.locale.en-US.yaml": "PackageIdentifier: Some.Tool\nPackageVersion: 1.0\nPackageLocale: en-US\nPackageName: Tool\nManifestType: locale\n",
+		"Tool.yaml":              "PackageIdentifier: Some.Tool\nPackageVersion: 1.0\nManifestType: version\n",
+	})
+
+	pv, err := readVersionDir(dir, []string{"Tool.locale.de-DE.yaml", "Tool.locale.en-US.yaml", "Tool.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pv == nil || pv.Name != "Tool" {
+		t.Errorf("readVersionDir = %+v, want the en-US name", pv)
+	}
+}
@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+
+	"setupforme/utils"
+
+	"github.com/lib/pq"
+)
+
+// Available reports whether a catalog has been imported
+func Available(db *sql.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM catalog_packages)").Scan(&exists)
+	return exists, err
+}
+
+// prefixQuery turns free text into a tsquery that matches every word as a
+// prefix, so that "visual stu" finds Visual Studio Code. Words are reduced to
+// letters and digits, which keeps tsquery operators out of the query.
+func prefixQuery(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// Search returns up to limit packages matching query, best match first. An
+// exact ID comes first; the rest are ordered by full-text rank plus trigram
+// similarity of the name or ID, which also catches misspellings. Versions
+// holds only the latest version. A search without matches returns
+// utils.ErrPackageNotFound.
+func Search(db *sql.DB, query string, limit int) ([]utils.WingetPackage, error) {
+	rows, err := db.Query(`
+		SELECT id, name, publisher, description, latest_version
+		FROM catalog_packages
+		WHERE ($1 <> '' AND search_vector @@ to_tsquery('simple', $1))
+			OR name % $2 OR id % $2
+		ORDER BY LOWER(id) = LOWER($2) DESC,
+			(CASE WHEN $1 <> '' THEN ts_rank(search_vector, to_tsquery('simple', $1)) ELSE 0 END)
+				+ GREATEST(similarity(name, $2), similarity(id, $2)) DESC,
+			id
+		LIMIT $3`, prefixQuery(query), query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packages []utils.WingetPackage
+	for rows.Next() {
+		var pkg utils.WingetPackage
+		var latest string
+		if err := rows.Scan(&pkg.Id, &pkg.Latest.Name, &pkg.Latest.Publisher, &pkg.Latest.Description, &latest); err != nil {
+			return nil, err
+		}
+		if latest != "" {
+			pkg.Versions = []string{latest}
+		}
+		packages = append(packages, pkg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(packages) == 0 {
+		return nil, utils.ErrPackageNotFound
+	}
+	return packages, nil
+}
+
+// Resolve returns the ID of the best match for an app name
+func Resolve(db *sql.DB, name string) (string, error) {
+	packages, err := Search(db, name, 1)
+	if err != nil {
+		return "", err
+	}
+	return packages[0].Id, nil
+}
+
+// Details returns a package with all its versions, newest first. The ID is
+// matched case-insensitively, as winget does.
+func Details(db *sql.DB, id string) (utils.WingetPackage, error) {
+	var pkg utils.WingetPackage
+	err := db.QueryRow(`
+		SELECT id, name, publisher, description, homepage, license
+		FROM catalog_packages
+		WHERE LOWER(id) = LOWER($1)`, id).Scan(&pkg.Id, &pkg.Latest.Name, &pkg.Latest.Publisher, &pkg.Latest.Description,
+		&pkg.Latest.Homepage, &pkg.Latest.License)
+	if err == sql.ErrNoRows {
+		return pkg, utils.ErrPackageNotFound
+	}
+	if err != nil {
+		return pkg, err
+	}
+
+	rows, err := db.Query("SELECT version FROM catalog_versions WHERE package_id = $1", pkg.Id)
+	if err != nil {
+		return pkg, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return pkg, err
+		}
+		pkg.Versions = append(pkg.Versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return pkg, err
+	}
+
+	sort.SliceStable(pkg.Versions, func(i, j int) bool {
+		return utils.CompareVersions(pkg.Versions[i], pkg.Versions[j]) > 0
+	})
+	return pkg, nil
+}
+
+// InstallerTypes returns the installer types (msi, wix, burn, exe, ...) of the
+// latest version of each package in ids, keyed by the lowercased package ID.
+// Packages missing from the catalog are left out.
+func InstallerTypes(db *sql.DB, ids []string) (map[string][]string, error) {
+	lower := make([]string, len(ids))
+	for i, id := range ids {
+		lower[i] = strings.ToLower(id)
+	}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT LOWER(p.id), i.installer_type
+		FROM catalog_packages p
+		JOIN catalog_installers i ON i.package_id = p.id AND i.version = p.latest_version
+		WHERE LOWER(p.id) = ANY($1)
+		ORDER BY 1, 2
+	`, pq.Array(lower))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := make(map[string][]string)
+	for rows.Next() {
+		var id, installerType string
+		if err := rows.Scan(&id, &installerType); err != nil {
+			return nil, err
+		}
+		types[id] = append(types[id], installerType)
+	}
+
+	return types, rows.Err()
+}
@@ -0,0 +1,50 @@
+package catalog
+
+import (
+	"database/sql"
+	"log"
+
+	"setupforme/utils"
+)
+
+// Source is a utils.PackageSource that answers from the catalog once one has
+// been imported, and from a fallback source such as winget.run until then
+type Source struct {
+	db       *sql.DB
+	fallback utils.PackageSource
+}
+
+func NewSource(db *sql.DB, fallback utils.PackageSource) *Source {
+	return &Source{db: db, fallback: fallback}
+}
+
+// imported reports whether lookups should go to the catalog
+func (s *Source) imported() bool {
+	ok, err := Available(s.db)
+	if err != nil {
+		log.Printf("catalog: %v", err)
+		return false
+	}
+	return ok
+}
+
+func (s *Source) Search(query string, take int) ([]utils.WingetPackage, error) {
+	if s.imported() {
+		return Search(s.db, query, take)
+	}
+	return s.fallback.Search(query, take)
+}
+
+func (s *Source) Resolve(name string) (string, error) {
+	if s.imported() {
+		return Resolve(s.db, name)
+	}
+	return s.fallback.Resolve(name)
+}
+
+func (s *Source) Details(id string) (utils.WingetPackage, error) {
+	if s.imported() {
+		return Details(s.db, id)
+	}
+	return s.fallback.Details(id)
+}
@@ -0,0 +1,41 @@
+// Command import-catalog builds the offline winget catalog from a local
+// checkout of https://github.com/microsoft/winget-pkgs:
+//
+//	go run ./cmd/import-catalog -manifests ~/src/winget-pkgs
+//
+// Once a catalog is imported, winget search, name resolution and version
+// checks use it instead of winget.run. Re-run it to pick up new manifests.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"setupforme/catalog"
+	"setupforme/database"
+)
+
+func main() {
+	manifests := flag.String("manifests", "", "path to a winget-pkgs checkout or its manifests directory")
+	flag.Parse()
+
+	if *manifests == "" {
+		log.Fatal("-manifests is required")
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	stats, err := catalog.Import(db, *manifests)
+	if err != nil {
+		log.Fatal("Failed to import catalog:", err)
+	}
+
+	log.Printf("Imported %d packages, %d versions and %d installers in %s (%d manifest directories skipped)",
+		stats.Packages, stats.Versions, stats.Installers, time.Since(start).Round(time.Second), stats.Skipped)
+}
@@ -0,0 +1,41 @@
+// Command seed-bundles loads curated bundles from a YAML file:
+//
+//	go run ./cmd/seed-bundles -file cmd/seed-bundles/bundles.yaml
+//
+// Bundles are matched by slug, so re-running it replaces the bundles in the
+// file and keeps any others admins created through the API. Package IDs are
+// checked against the offline catalog, or winget.run when none is imported.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"setupforme/bundles"
+	"setupforme/catalog"
+	"setupforme/database"
+	"setupforme/utils"
+)
+
+func main() {
+	file := flag.String("file", "", "path to a bundles YAML file")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	source := catalog.NewSource(db, utils.NewCachedSource(utils.NewWingetRunSource(utils.WingetRunAPI, nil)))
+	stats, err := bundles.Seed(db, source, *file)
+	if err != nil {
+		log.Fatal("Failed to seed bundles:", err)
+	}
+
+	log.Printf("Created %d and replaced %d bundles with %d packages", stats.Created, stats.Updated, stats.Packages)
+}
@@ -45,7 +45,10 @@ func createTables(db *sql.DB) error {
 		id SERIAL PRIMARY KEY,
 		email VARCHAR(255) NOT NULL UNIQUE,
 		password VARCHAR(255) NOT NULL
-	);`
+	);
+
+	-- Granted outside the app, e.g. with UPDATE users SET is_admin = TRUE
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;`
 
 	if _, err := db.Exec(userSchema); err != nil {
 		return err
@@ -67,5 +70,255 @@ func createTables(db *sql.DB) error {
 		return err
 	}
 
+	// Columns added after the initial schema
+	appColumns := `
+	ALTER TABLE apps
+		ADD COLUMN IF NOT EXISTS apt_package VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS dnf_package VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS flatpak_id VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS brew_package VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS brew_cask BOOLEAN NOT NULL DEFAULT FALSE,
+		ADD COLUMN IF NOT EXISTS version VARCHAR(128),
+		ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64),
+		ADD COLUMN IF NOT EXISTS install_policy VARCHAR(16),
+		ADD COLUMN IF NOT EXISTS uninstall_command TEXT,
+		ADD COLUMN IF NOT EXISTS installer_type VARCHAR(16),
+		ADD COLUMN IF NOT EXISTS scope VARCHAR(16),
+		ADD COLUMN IF NOT EXISTS requires_admin BOOLEAN NOT NULL DEFAULT FALSE,
+		ADD COLUMN IF NOT EXISTS reboot_behavior VARCHAR(16),
+		ADD COLUMN IF NOT EXISTS winget_id_resolution VARCHAR(16),
+		ADD COLUMN IF NOT EXISTS publisher VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS homepage TEXT,
+		ADD COLUMN IF NOT EXISTS license VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS description TEXT,
+		ADD COLUMN IF NOT EXISTS icon_url TEXT,
+		ADD COLUMN IF NOT EXISTS latest_version VARCHAR(128),
+		ADD COLUMN IF NOT EXISTS enriched_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS metadata_attempted_at TIMESTAMPTZ;`
+
+	if _, err := db.Exec(appColumns); err != nil {
+		return err
+	}
+
+	// Named setup profiles. Every app belongs to one; apps created before
+	// profiles existed are moved into a default profile per user. The foreign
+	// key is added on its own so that re-running this does not duplicate it.
+	profileSchema := `
+	CREATE TABLE IF NOT EXISTS profiles (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE(user_id, name),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS profiles_one_default_idx ON profiles (user_id) WHERE is_default;
+
+	ALTER TABLE apps ADD COLUMN IF NOT EXISTS profile_id INTEGER;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'apps_profile_id_fkey') THEN
+			ALTER TABLE apps ADD CONSTRAINT apps_profile_id_fkey
+				FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE;
+		END IF;
+	END $$;
+
+	INSERT INTO profiles (user_id, name, is_default)
+	SELECT DISTINCT a.user_id, 'Default', TRUE
+	FROM apps a
+	WHERE a.profile_id IS NULL
+		AND NOT EXISTS (SELECT 1 FROM profiles p WHERE p.user_id = a.user_id AND p.is_default)
+	ON CONFLICT DO NOTHING;
+
+	UPDATE apps SET profile_id = p.id
+	FROM profiles p
+	WHERE apps.profile_id IS NULL AND p.user_id = apps.user_id AND p.is_default;
+
+	ALTER TABLE apps ALTER COLUMN profile_id SET NOT NULL;
+
+	CREATE INDEX IF NOT EXISTS apps_profile_id_idx ON apps (profile_id);`
+
+	if _, err := db.Exec(profileSchema); err != nil {
+		return err
+	}
+
+	// Profile sharing. A forked profile and its apps remember the upstream
+	// profile and apps they were copied from. The columns are added plain and
+	// their constraints separately, under the names Postgres would have given
+	// them inline, so running this again never adds a second copy.
+	shareSchema := `
+	ALTER TABLE profiles
+		ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'private',
+		ADD COLUMN IF NOT EXISTS slug VARCHAR(64),
+		ADD COLUMN IF NOT EXISTS upstream_profile_id INTEGER,
+		ADD COLUMN IF NOT EXISTS forked_at TIMESTAMPTZ;
+
+	ALTER TABLE apps ADD COLUMN IF NOT EXISTS upstream_app_id INTEGER;
+
+	CREATE UNIQUE INDEX IF NOT EXISTS profiles_slug_key ON profiles (slug);
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'profiles_upstream_profile_id_fkey') THEN
+			ALTER TABLE profiles ADD CONSTRAINT profiles_upstream_profile_id_fkey
+				FOREIGN KEY (upstream_profile_id) REFERENCES profiles(id) ON DELETE SET NULL;
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'apps_upstream_app_id_fkey') THEN
+			ALTER TABLE apps ADD CONSTRAINT apps_upstream_app_id_fkey
+				FOREIGN KEY (upstream_app_id) REFERENCES apps(id) ON DELETE SET NULL;
+		END IF;
+	END $$;
+
+	CREATE INDEX IF NOT EXISTS profiles_public_idx ON profiles (created_at) WHERE visibility = 'public';`
+
+	if _, err := db.Exec(shareSchema); err != nil {
+		return err
+	}
+
+	// Install-order prerequisites between apps
+	dependencySchema := `
+	CREATE TABLE IF NOT EXISTS app_dependencies (
+		app_id INTEGER NOT NULL,
+		depends_on_id INTEGER NOT NULL,
+		PRIMARY KEY(app_id, depends_on_id),
+		FOREIGN KEY(app_id) REFERENCES apps(id) ON DELETE CASCADE,
+		FOREIGN KEY(depends_on_id) REFERENCES apps(id) ON DELETE CASCADE
+	);`
+
+	if _, err := db.Exec(dependencySchema); err != nil {
+		return err
+	}
+
+	// Shareable script links (only the token hash is stored)
+	linkSchema := `
+	CREATE TABLE IF NOT EXISTS script_links (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		params TEXT NOT NULL DEFAULT '',
+		single_use BOOLEAN NOT NULL DEFAULT FALSE,
+		expires_at TIMESTAMPTZ NOT NULL,
+		revoked_at TIMESTAMPTZ,
+		access_count INTEGER NOT NULL DEFAULT 0,
+		last_accessed_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	if _, err := db.Exec(linkSchema); err != nil {
+		return err
+	}
+
+	// Script runs that report per-app results back (only the token hash is stored)
+	runSchema := `
+	CREATE TABLE IF NOT EXISTS install_runs (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		params TEXT NOT NULL DEFAULT '',
+		hostname VARCHAR(255),
+		os_build VARCHAR(64),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_reported_at TIMESTAMPTZ,
+		FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS install_results (
+		id SERIAL PRIMARY KEY,
+		run_id INTEGER NOT NULL,
+		app_id INTEGER,
+		app_name VARCHAR(255) NOT NULL,
+		result VARCHAR(64) NOT NULL,
+		exit_code INTEGER,
+		duration_ms BIGINT,
+		error TEXT,
+		reported_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		FOREIGN KEY(run_id) REFERENCES install_runs(id) ON DELETE CASCADE,
+		FOREIGN KEY(app_id) REFERENCES apps(id) ON DELETE SET NULL
+	);`
+
+	if _, err := db.Exec(runSchema); err != nil {
+		return err
+	}
+
+	// Offline winget catalog imported from a winget-pkgs checkout (see cmd/import-catalog)
+	catalogSchema := `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+	CREATE TABLE IF NOT EXISTS catalog_packages (
+		id VARCHAR(255) PRIMARY KEY,
+		name TEXT NOT NULL DEFAULT '',
+		publisher TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		homepage TEXT NOT NULL DEFAULT '',
+		license TEXT NOT NULL DEFAULT '',
+		moniker TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '',
+		latest_version VARCHAR(128) NOT NULL DEFAULT '',
+		imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		search_vector TSVECTOR GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', replace(id, '.', ' ') || ' ' || name || ' ' || moniker), 'A') ||
+			setweight(to_tsvector('simple', publisher || ' ' || tags), 'B') ||
+			setweight(to_tsvector('simple', description), 'C')
+		) STORED
+	);
+
+	CREATE INDEX IF NOT EXISTS catalog_packages_search_idx ON catalog_packages USING GIN (search_vector);
+	CREATE INDEX IF NOT EXISTS catalog_packages_name_trgm_idx ON catalog_packages USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS catalog_packages_id_trgm_idx ON catalog_packages USING GIN (id gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS catalog_packages_lower_id_idx ON catalog_packages (LOWER(id));
+
+	CREATE TABLE IF NOT EXISTS catalog_versions (
+		package_id VARCHAR(255) NOT NULL,
+		version VARCHAR(128) NOT NULL,
+		PRIMARY KEY(package_id, version),
+		FOREIGN KEY(package_id) REFERENCES catalog_packages(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS catalog_installers (
+		id SERIAL PRIMARY KEY,
+		package_id VARCHAR(255) NOT NULL,
+		version VARCHAR(128) NOT NULL,
+		architecture VARCHAR(16) NOT NULL DEFAULT '',
+		installer_type VARCHAR(16) NOT NULL DEFAULT '',
+		scope VARCHAR(16) NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		sha256 VARCHAR(64) NOT NULL DEFAULT '',
+		FOREIGN KEY(package_id, version) REFERENCES catalog_versions(package_id, version) ON DELETE CASCADE
+	);`
+
+	if _, err := db.Exec(catalogSchema); err != nil {
+		return err
+	}
+
+	// Curated bundles of packages managed by admins (see cmd/seed-bundles)
+	bundleSchema := `
+	CREATE TABLE IF NOT EXISTS bundles (
+		id SERIAL PRIMARY KEY,
+		slug VARCHAR(64) NOT NULL UNIQUE,
+		name VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS bundle_packages (
+		bundle_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		winget_id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL DEFAULT '',
+		args TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY(bundle_id, position),
+		FOREIGN KEY(bundle_id) REFERENCES bundles(id) ON DELETE CASCADE
+	);`
+
+	if _, err := db.Exec(bundleSchema); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -11,3 +11,5 @@ require (
 )
 
 require github.com/joho/godotenv v1.5.1
+
+require gopkg.in/yaml.v3 v3.0.1
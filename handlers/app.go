@@ -3,55 +3,121 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
+	"setupforme/catalog"
 	"setupforme/models"
 	"setupforme/utils"
 )
 
 type AppHandler struct {
-	db *sql.DB
+	db     *sql.DB
+	source utils.PackageSource // resolves and checks winget packages
 }
 
-func NewAppHandler(db *sql.DB) *AppHandler {
-	return &AppHandler{db: db}
+func NewAppHandler(db *sql.DB, source utils.PackageSource) *AppHandler {
+	return &AppHandler{db: db, source: source}
 }
 
-func (h *AppHandler) GetApps(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user_id").(int)
+// maxFieldLength bounds the app fields stored as VARCHAR(255)
+const maxFieldLength = 255
+
+// appColumns lists the apps columns in the order expected by scanApp
+const appColumns = `id, user_id, profile_id, name, winget_id, winget_id_resolution, download_url, sha256, installer_type, uninstall_command,
+	args, version, install_policy, scope, requires_admin, reboot_behavior, apt_package, dnf_package, flatpak_id, brew_package, brew_cask,
+	` + metadataColumns
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApp(row rowScanner) (models.App, error) {
+	var app models.App
+	var name, wingetID, downloadURL, sha256, installerType, uninstallCommand, args, version, installPolicy, scope sql.NullString
+	var rebootBehavior, aptPackage, dnfPackage, flatpakID, brewPackage, resolution sql.NullString
+	var meta nullMetadata
+
+	dest := []interface{}{&app.ID, &app.UserID, &app.ProfileID, &name, &wingetID, &resolution, &downloadURL, &sha256, &installerType, &uninstallCommand,
+		&args, &version, &installPolicy, &scope, &app.RequiresAdmin, &rebootBehavior,
+		&aptPackage, &dnfPackage, &flatpakID, &brewPackage, &app.BrewCask}
+	err := row.Scan(append(dest, meta.dest()...)...)
+	if err != nil {
+		return app, err
+	}
+
+	app.Name = name.String
+	app.WingetID = wingetID.String
+	app.WingetIDResolution = resolution.String
+	app.DownloadURL = downloadURL.String
+	app.SHA256 = sha256.String
+	app.InstallerType = installerType.String
+	app.UninstallCommand = uninstallCommand.String
+	app.Args = args.String
+	app.Version = version.String
+	app.InstallPolicy = installPolicy.String
+	app.Scope = scope.String
+	app.RebootBehavior = rebootBehavior.String
+	app.AptPackage = aptPackage.String
+	app.DnfPackage = dnfPackage.String
+	app.FlatpakID = flatpakID.String
+	app.BrewPackage = brewPackage.String
+	app.PackageMetadata = meta.value()
+
+	return app, nil
+}
 
+// fetchApps loads the apps in one of a user's profiles, or all of the user's
+// apps when profileID is 0
+func (h *AppHandler) fetchApps(userID, profileID int) ([]models.App, error) {
 	rows, err := h.db.Query(`
-		SELECT id, user_id, name, winget_id, download_url, args 
-		FROM apps WHERE user_id = $1
-	`, userID)
+		SELECT `+appColumns+`
+		FROM apps WHERE user_id = $1 AND ($2 = 0 OR profile_id = $2)
+		ORDER BY id
+	`, userID, profileID)
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
 	var apps []models.App
 	for rows.Next() {
-		var app models.App
-		var name, wingetID, downloadURL, args sql.NullString
-
-		err := rows.Scan(&app.ID, &app.UserID, &name, &wingetID, &downloadURL, &args)
+		app, err := scanApp(rows)
 		if err != nil {
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to scan app")
-			return
+			return nil, err
 		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	deps, err := h.loadDependencies(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		apps[i].DependsOn = deps[apps[i].ID]
+	}
 
-		app.Name = name.String
-		app.WingetID = wingetID.String
-		app.DownloadURL = downloadURL.String
-		app.Args = args.String
+	return apps, nil
+}
 
-		apps = append(apps, app)
+func (h *AppHandler) GetApps(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	apps, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
 	}
 
 	if apps == nil {
@@ -76,42 +142,95 @@ func (h *AppHandler) CreateApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Try to auto-resolve winget id by name if both ID and URL are missing
-	if strings.TrimSpace(req.WingetID) == "" && strings.TrimSpace(req.DownloadURL) == "" {
-		if id, err := utils.ResolveWingetID(req.Name); err == nil && id != "" {
-			req.WingetID = id
-		} else {
-			writeErrorResponse(w, http.StatusBadRequest, "Either winget_id or download_url is required (auto-resolve failed)")
+	profileID, ok := h.bodyProfile(w, userID, req.ProfileID)
+	if !ok {
+		return
+	}
+	req.ProfileID = profileID
+
+	// Try to auto-resolve winget id by name if no installer source was given.
+	// When the name fits several packages, the client picks one and sends it
+	// back as winget_id.
+	resolution := resolutionConfirmed
+	if !hasInstallSource(req) {
+		packages, err := h.source.Search(req.Name, resolveCandidates)
+		if err != nil && !errors.Is(err, utils.ErrPackageNotFound) {
+			writeSourceError(w, err)
 			return
 		}
-	}
 
-	// Validate download URL if provided
-	if req.DownloadURL != "" {
-		if !isValidURL(req.DownloadURL) {
-			writeErrorResponse(w, http.StatusBadRequest, "Invalid download URL")
+		candidates := rankCandidates(req.Name, packages)
+		if len(candidates) == 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "Either winget_id, download_url or a platform package is required (auto-resolve failed)")
 			return
 		}
+		if !isConfident(candidates) {
+			w.WriteHeader(http.StatusMultipleChoices)
+			json.NewEncoder(w).Encode(AmbiguousResolutionResponse{
+				Error:      http.StatusText(http.StatusMultipleChoices),
+				Message:    "Several packages match " + req.Name + "; send the chosen one as winget_id",
+				Candidates: candidates,
+			})
+			return
+		}
+		req.WingetID = candidates[0].ID
+		resolution = resolutionAuto
+	}
+
+	if msg := validateAppRequest(&req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !h.checkWingetPackage(w, &req, true) {
+		return
+	}
+
+	if msg, err := h.checkDependencies(userID, req.ProfileID, 0, req.DependsOn); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
 	}
 
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
 	var appID int
-	err := h.db.QueryRow(`
-		INSERT INTO apps (user_id, name, winget_id, download_url, args) 
-		VALUES ($1, $2, $3, $4, $5)
+	err = tx.QueryRow(`
+		INSERT INTO apps (user_id, profile_id, name, winget_id, winget_id_resolution, download_url, sha256, installer_type,
+			uninstall_command, args, version, install_policy, scope, requires_admin, reboot_behavior,
+			apt_package, dnf_package, flatpak_id, brew_package, brew_cask)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		RETURNING id
-	`, userID, req.Name, req.WingetID, req.DownloadURL, req.Args).Scan(&appID)
+	`, userID, req.ProfileID, req.Name, req.WingetID, wingetIDResolution(req.WingetID, resolution), req.DownloadURL, req.SHA256,
+		req.InstallerType, req.UninstallCommand, req.Args, req.Version, req.InstallPolicy, req.Scope,
+		req.RequiresAdmin, req.RebootBehavior, req.AptPackage, req.DnfPackage, req.FlatpakID, req.BrewPackage,
+		req.BrewCask).Scan(&appID)
 	if err != nil {
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create app")
 		return
 	}
 
-	app := models.App{
-		ID:          int(appID),
-		UserID:      userID,
-		Name:        req.Name,
-		WingetID:    req.WingetID,
-		DownloadURL: req.DownloadURL,
-		Args:        req.Args,
+	if err := saveDependencies(tx, appID, req.DependsOn); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to save dependencies")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create app")
+		return
+	}
+
+	app := appFromRequest(appID, userID, req)
+	app.WingetIDResolution = wingetIDResolution(req.WingetID, resolution)
+	if req.WingetID != "" {
+		app.PackageMetadata = h.enrichApp(appID, req.WingetID)
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -128,7 +247,9 @@ func (h *AppHandler) UpdateApp(w http.ResponseWriter, r *http.Request) {
 
 	// Check if app exists and belongs to user
 	var existingUserID int
-	err = h.db.QueryRow("SELECT user_id FROM apps WHERE id = $1", appID).Scan(&existingUserID)
+	var oldWingetID sql.NullString
+	var oldProfileID int
+	err = h.db.QueryRow("SELECT user_id, winget_id, profile_id FROM apps WHERE id = $1", appID).Scan(&existingUserID, &oldWingetID, &oldProfileID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			writeErrorResponse(w, http.StatusNotFound, "App not found")
@@ -155,41 +276,237 @@ func (h *AppHandler) UpdateApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate that at least winget_id or download_url is provided
-	if strings.TrimSpace(req.WingetID) == "" && strings.TrimSpace(req.DownloadURL) == "" {
-		writeErrorResponse(w, http.StatusBadRequest, "Either winget_id or download_url is required")
+	// An app stays in its profile unless the request moves it
+	if req.ProfileID == 0 {
+		req.ProfileID = oldProfileID
+	} else if _, ok := h.bodyProfile(w, userID, req.ProfileID); !ok {
 		return
 	}
 
-	// Validate download URL if provided
-	if req.DownloadURL != "" {
-		if !isValidURL(req.DownloadURL) {
-			writeErrorResponse(w, http.StatusBadRequest, "Invalid download URL")
+	// Validate that at least one installer source is provided
+	if !hasInstallSource(req) {
+		writeErrorResponse(w, http.StatusBadRequest, "Either winget_id, download_url or a platform package is required")
+		return
+	}
+
+	if msg := validateAppRequest(&req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !h.checkWingetPackage(w, &req, !strings.EqualFold(req.WingetID, oldWingetID.String)) {
+		return
+	}
+
+	if msg, err := h.checkDependencies(userID, req.ProfileID, appID, req.DependsOn); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Apps left behind in the old profile can no longer depend on a moved app
+	if req.ProfileID != oldProfileID {
+		_, err = tx.Exec(`
+			DELETE FROM app_dependencies
+			WHERE depends_on_id = $1 AND app_id IN (SELECT id FROM apps WHERE profile_id <> $2)
+		`, appID, req.ProfileID)
+		if err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to update app")
 			return
 		}
 	}
 
-	_, err = h.db.Exec(`
-		UPDATE apps SET name = $1, winget_id = $2, download_url = $3, args = $4 
-		WHERE id = $5
-	`, req.Name, req.WingetID, req.DownloadURL, req.Args, appID)
+	// A winget_id the user changes counts as confirmed; an unchanged one keeps its resolution
+	var resolution sql.NullString
+	var meta nullMetadata
+	err = tx.QueryRow(`
+		UPDATE apps SET name = $1, winget_id = $2, download_url = $3, sha256 = $4, installer_type = $5,
+			uninstall_command = $6, args = $7, version = $8, install_policy = $9, scope = $10,
+			requires_admin = $11, reboot_behavior = $12, apt_package = $13, dnf_package = $14, flatpak_id = $15,
+			brew_package = $16, brew_cask = $17, profile_id = $19,
+			winget_id_resolution = CASE
+				WHEN $2 = '' THEN NULL
+				WHEN winget_id IS DISTINCT FROM $2 THEN 'confirmed'
+				ELSE winget_id_resolution
+			END
+		WHERE id = $18
+		RETURNING winget_id_resolution, `+metadataColumns+`
+	`, req.Name, req.WingetID, req.DownloadURL, req.SHA256, req.InstallerType, req.UninstallCommand,
+		req.Args, req.Version, req.InstallPolicy, req.Scope, req.RequiresAdmin, req.RebootBehavior,
+		req.AptPackage, req.DnfPackage, req.FlatpakID, req.BrewPackage, req.BrewCask, appID,
+		req.ProfileID).Scan(append([]interface{}{&resolution}, meta.dest()...)...)
 	if err != nil {
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update app")
 		return
 	}
 
-	app := models.App{
-		ID:          appID,
-		UserID:      userID,
-		Name:        req.Name,
-		WingetID:    req.WingetID,
-		DownloadURL: req.DownloadURL,
-		Args:        req.Args,
+	if err := saveDependencies(tx, appID, req.DependsOn); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to save dependencies")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update app")
+		return
+	}
+
+	app := appFromRequest(appID, userID, req)
+	app.WingetIDResolution = resolution.String
+	app.PackageMetadata = meta.value()
+	if req.WingetID != oldWingetID.String {
+		app.PackageMetadata = h.enrichApp(appID, req.WingetID)
 	}
 
 	json.NewEncoder(w).Encode(app)
 }
 
+// wingetIDResolution returns how a stored winget_id was chosen, or "" without one
+func wingetIDResolution(wingetID, resolution string) string {
+	if wingetID == "" {
+		return ""
+	}
+	return resolution
+}
+
+// validateAppRequest normalizes and checks the fields shared by create and
+// update. It returns a client-facing message, or "" when the request is valid.
+func validateAppRequest(req *models.CreateAppRequest) string {
+	// Text columns are VARCHAR(255); longer values would fail the insert
+	for _, field := range []struct{ name, value string }{
+		{"name", req.Name},
+		{"winget_id", req.WingetID},
+		{"apt_package", req.AptPackage},
+		{"dnf_package", req.DnfPackage},
+		{"flatpak_id", req.FlatpakID},
+		{"brew_package", req.BrewPackage},
+	} {
+		if len(field.value) > maxFieldLength {
+			return fmt.Sprintf("%s must be at most %d characters", field.name, maxFieldLength)
+		}
+	}
+
+	// Validate download URL if provided
+	if req.DownloadURL != "" {
+		if !isValidURL(req.DownloadURL) {
+			return "Invalid download URL"
+		}
+	}
+
+	// A checksum pins the installer behind download_url
+	if req.SHA256 != "" {
+		req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+		if req.DownloadURL == "" {
+			return "sha256 requires download_url"
+		}
+		if !utils.IsValidSHA256(req.SHA256) {
+			return "sha256 must be 64 hexadecimal characters"
+		}
+	}
+
+	// The installer type defaults to what the URL's extension suggests
+	if req.InstallerType != "" || req.DownloadURL != "" {
+		req.InstallerType = strings.ToLower(strings.TrimSpace(req.InstallerType))
+		if req.DownloadURL == "" {
+			return "installer_type requires download_url"
+		}
+		if req.InstallerType == "" {
+			req.InstallerType = utils.InstallerTypeFromURL(req.DownloadURL)
+		} else if !utils.IsValidInstallerType(req.InstallerType) {
+			return "installer_type must be msi, nsis, inno, wix-burn, exe-unknown, msix or zip"
+		}
+	}
+
+	// A version pin only makes sense for winget packages
+	if req.Version != "" {
+		if req.WingetID == "" {
+			return "version requires winget_id"
+		}
+		if !utils.IsValidPackageVersion(req.Version) {
+			return "Invalid version"
+		}
+	}
+
+	if req.InstallPolicy != "" && !isValidPolicy(req.InstallPolicy) {
+		return "install_policy must be skip, upgrade or force"
+	}
+
+	// Scope maps to winget's --scope; a user-scope install never needs elevation
+	if req.Scope != "" {
+		req.Scope = strings.ToLower(strings.TrimSpace(req.Scope))
+		if req.Scope != scopeUser && req.Scope != scopeMachine {
+			return "scope must be user or machine"
+		}
+		if req.WingetID == "" {
+			return "scope requires winget_id"
+		}
+		if req.Scope == scopeUser && req.RequiresAdmin {
+			return "requires_admin cannot be combined with scope user"
+		}
+	}
+
+	if req.RebootBehavior != "" {
+		req.RebootBehavior = strings.ToLower(strings.TrimSpace(req.RebootBehavior))
+		if req.RebootBehavior != rebootIgnore && req.RebootBehavior != rebootDefer && req.RebootBehavior != rebootImmediate {
+			return "reboot_behavior must be ignore, defer or immediate"
+		}
+	}
+
+	// Package identifiers reach the package managers as arguments; quoting
+	// keeps them out of the shell, but one starting with "-" would be an option
+	packages := []struct{ field, value string }{
+		{"winget_id", req.WingetID},
+		{"apt_package", req.AptPackage},
+		{"dnf_package", req.DnfPackage},
+		{"flatpak_id", req.FlatpakID},
+		{"brew_package", req.BrewPackage},
+	}
+	for _, pkg := range packages {
+		if strings.HasPrefix(strings.TrimSpace(pkg.value), "-") {
+			return pkg.field + " cannot start with -"
+		}
+	}
+
+	req.DependsOn = normalizeDependencies(req.DependsOn)
+
+	return ""
+}
+
+// appFromRequest builds the API representation of a stored app
+func appFromRequest(appID, userID int, req models.CreateAppRequest) models.App {
+	return models.App{
+		ID:               appID,
+		UserID:           userID,
+		ProfileID:        req.ProfileID,
+		Name:             req.Name,
+		WingetID:         req.WingetID,
+		DownloadURL:      req.DownloadURL,
+		SHA256:           req.SHA256,
+		InstallerType:    req.InstallerType,
+		UninstallCommand: req.UninstallCommand,
+		Args:             req.Args,
+		Version:          req.Version,
+		AptPackage:       req.AptPackage,
+		InstallPolicy:    req.InstallPolicy,
+		Scope:            req.Scope,
+		RequiresAdmin:    req.RequiresAdmin,
+		RebootBehavior:   req.RebootBehavior,
+		DnfPackage:       req.DnfPackage,
+		FlatpakID:        req.FlatpakID,
+		BrewPackage:      req.BrewPackage,
+		BrewCask:         req.BrewCask,
+		DependsOn:        req.DependsOn,
+	}
+}
+
 func (h *AppHandler) DeleteApp(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(int)
 	appID, err := strconv.Atoi(r.PathValue("id"))
@@ -224,104 +541,115 @@ func (h *AppHandler) DeleteApp(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GenerateScript renders an installer script for the user's apps.
+// Query parameters are described by parseScriptOptions.
 func (h *AppHandler) GenerateScript(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(int)
 
-	rows, err := h.db.Query(`
-		SELECT name, winget_id, download_url, args 
-		FROM apps WHERE user_id = $1
-	`, userID)
+	opts, err := parseScriptOptions(r.URL.Query())
 	if err != nil {
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer rows.Close()
 
-	// PowerShell single-quote wrapper to safely include arbitrary text
-	psSingle := func(s string) string {
-		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
-	}
-
-	var scriptLines []string
-	scriptLines = append(scriptLines, "# SetupForMe - Generated Installation Script")
-	scriptLines = append(scriptLines, fmt.Sprintf("# Generated on: %s", time.Now().Format("2006-01-02 15:04:05")))
-	scriptLines = append(scriptLines, "")
-	scriptLines = append(scriptLines, "$ErrorActionPreference = 'Stop'")
-	scriptLines = append(scriptLines, "")
-	// Helper functions to make installs robust
-	scriptLines = append(scriptLines, "function Install-WingetApp { param([string]$Id, [string]$Args)")
-	scriptLines = append(scriptLines, "  $argList = \"-e --id $Id --accept-source-agreements --accept-package-agreements\"")
-	scriptLines = append(scriptLines, "  if ($Args -and $Args.Trim() -ne '') { $argList = \"$argList $Args\" }")
-	scriptLines = append(scriptLines, "  Write-Host \"winget $argList\" -ForegroundColor Cyan")
-	scriptLines = append(scriptLines, "  Start-Process 'winget' -ArgumentList $argList -Wait -NoNewWindow")
-	scriptLines = append(scriptLines, "}")
-	scriptLines = append(scriptLines, "")
-	scriptLines = append(scriptLines, "function Install-FromUrl { param([string]$Url, [string]$Args)")
-	scriptLines = append(scriptLines, "  $fileName = [System.IO.Path]::GetFileName(([System.Uri]$Url).AbsolutePath)")
-	scriptLines = append(scriptLines, "  if ([string]::IsNullOrWhiteSpace($fileName)) { $fileName = 'installer.exe' }")
-	scriptLines = append(scriptLines, "  $dest = Join-Path $env:TEMP (\"SetupForMe_\" + [guid]::NewGuid().ToString() + '_' + $fileName)")
-	scriptLines = append(scriptLines, "  Write-Host \"Downloading $Url to $dest\" -ForegroundColor DarkCyan")
-	scriptLines = append(scriptLines, "  Invoke-WebRequest -Uri $Url -OutFile $dest")
-	scriptLines = append(scriptLines, "  $psi = New-Object System.Diagnostics.ProcessStartInfo")
-	scriptLines = append(scriptLines, "  $psi.FileName = $dest")
-	scriptLines = append(scriptLines, "  if ($Args -and $Args.Trim() -ne '') { $psi.Arguments = $Args }")
-	scriptLines = append(scriptLines, "  $psi.UseShellExecute = $true")
-	scriptLines = append(scriptLines, "  $p = [System.Diagnostics.Process]::Start($psi)")
-	scriptLines = append(scriptLines, "  $p.WaitForExit()")
-	scriptLines = append(scriptLines, "}")
-	scriptLines = append(scriptLines, "")
-	scriptLines = append(scriptLines, "Write-Host 'Starting application installation...' -ForegroundColor Green")
-	scriptLines = append(scriptLines, "")
-
-	appCount := 0
-	for rows.Next() {
-		var name, wingetID, downloadURL, args sql.NullString
-		err := rows.Scan(&name, &wingetID, &downloadURL, &args)
-		if err != nil {
-			writeErrorResponse(w, http.StatusInternalServerError, "Failed to scan app")
+	h.writeScript(w, r, userID, opts)
+}
+
+// writeScript renders the script for opts and writes it as the JSON response
+// shared by the script endpoints
+func (h *AppHandler) writeScript(w http.ResponseWriter, r *http.Request, userID int, opts scriptOptions) {
+	script, err := h.renderScript(userID, opts, publicBaseURL(r))
+	if err != nil {
+		if errors.Is(err, errProfileNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
 			return
 		}
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate script")
+		return
+	}
+
+	response := models.SuccessResponse{
+		Message: "Script generated successfully",
+		Data:    map[string]string{"script": script, "target": opts.Target},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
 
-		appCount++
-		appName := name.String
-		if appName == "" {
-			appName = "Unknown App"
+// renderScript loads the apps of the profile picked by opts and renders them
+// with the given options. A reporting script gets a new run whose results are
+// posted under baseURL.
+func (h *AppHandler) renderScript(userID int, opts scriptOptions, baseURL string) (string, error) {
+	profileID, err := h.lookupProfile(userID, opts.ProfileID)
+	if err != nil {
+		return "", err
+	}
+
+	apps, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.Report {
+		runID, token, err := h.startRun(userID, opts)
+		if err != nil {
+			return "", err
 		}
+		opts.ReportURL = fmt.Sprintf("%s/api/runs/%d/results", baseURL, runID)
+		opts.ReportToken = token
+	}
 
-		// Prepare values wrapped for single-quoted PowerShell strings
-		psWinget := psSingle(wingetID.String)
-		psURL := psSingle(downloadURL.String)
-		psArgs := psSingle(args.String)
-
-		scriptLines = append(scriptLines, fmt.Sprintf("# App %d: %s", appCount, appName))
-		scriptLines = append(scriptLines, fmt.Sprintf("Write-Host 'Installing %s...' -ForegroundColor Yellow", appName))
-		scriptLines = append(scriptLines, "try {")
-		if wingetID.String != "" {
-			scriptLines = append(scriptLines, fmt.Sprintf("  Install-WingetApp %s %s", psWinget, psArgs))
-		} else if downloadURL.String != "" {
-			scriptLines = append(scriptLines, fmt.Sprintf("  Install-FromUrl %s %s", psURL, psArgs))
-		} else {
-			scriptLines = append(scriptLines, "  Write-Host 'No installer info provided.' -ForegroundColor DarkYellow")
+	if opts.Target == targetPowerShell && opts.Parallel {
+		if opts.WindowsInstaller, err = h.windowsInstallerApps(apps); err != nil {
+			return "", err
 		}
-		scriptLines = append(scriptLines, fmt.Sprintf("  Write-Host 'Finished: %s' -ForegroundColor Green", appName))
-		scriptLines = append(scriptLines, "} catch { Write-Host ('Failed: ' + '"+strings.ReplaceAll(appName, "'", "''")+"' + ' - ' + $_.Exception.Message) -ForegroundColor Red }")
-		scriptLines = append(scriptLines, "")
 	}
 
-	if appCount == 0 {
-		scriptLines = append(scriptLines, `Write-Host "No applications to install." -ForegroundColor Yellow`)
-	} else {
-		scriptLines = append(scriptLines, `Write-Host "Installation complete!" -ForegroundColor Green`)
+	return scriptGenerators[opts.Target](processingOrder(apps, opts.Mode), opts), nil
+}
+
+// windowsInstallerTypes are the catalog installer types that run Windows
+// Installer: MSI packages, MSIs built with WiX and WiX Burn bundles
+var windowsInstallerTypes = map[string]bool{"msi": true, "wix": true, "burn": true}
+
+// windowsInstallerApps looks up the installer types of the winget apps in
+// the catalog, for scriptOptions.WindowsInstaller
+func (h *AppHandler) windowsInstallerApps(apps []models.App) (map[string]bool, error) {
+	var ids []string
+	for _, app := range apps {
+		if app.WingetID != "" {
+			ids = append(ids, app.WingetID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
 	}
 
-	script := strings.Join(scriptLines, "\n")
+	types, err := catalog.InstallerTypes(h.db, ids)
+	if err != nil {
+		return nil, err
+	}
 
-	response := models.SuccessResponse{
-		Message: "Script generated successfully",
-		Data:    map[string]string{"script": script},
+	uses := make(map[string]bool, len(types))
+	for id, list := range types {
+		uses[id] = false
+		for _, t := range list {
+			if windowsInstallerTypes[t] {
+				uses[id] = true
+			}
+		}
 	}
+	return uses, nil
+}
 
-	json.NewEncoder(w).Encode(response)
+// hasInstallSource reports whether the request carries any way to install the app
+func hasInstallSource(req models.CreateAppRequest) bool {
+	for _, v := range []string{req.WingetID, req.DownloadURL, req.AptPackage, req.DnfPackage, req.FlatpakID, req.BrewPackage} {
+		if strings.TrimSpace(v) != "" {
+			return true
+		}
+	}
+	return false
 }
 
 func isValidURL(rawURL string) bool {
@@ -341,3 +669,92 @@ func isValidURL(rawURL string) bool {
 
 	return true
 }
+
+// ComputeChecksum downloads an app's installer once and records its SHA-256
+func (h *AppHandler) ComputeChecksum(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	appID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	app, err := scanApp(h.db.QueryRow("SELECT "+appColumns+" FROM apps WHERE id = $1", appID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "App not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	if app.UserID != userID {
+		writeErrorResponse(w, http.StatusForbidden, "You can only update your own apps")
+		return
+	}
+
+	if app.DownloadURL == "" || !isValidURL(app.DownloadURL) {
+		writeErrorResponse(w, http.StatusBadRequest, "App has no valid download_url")
+		return
+	}
+
+	sum, err := utils.ComputeURLSHA256(app.DownloadURL)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadGateway, "Failed to download installer: "+err.Error())
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE apps SET sha256 = $1 WHERE id = $2", sum, appID); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update app")
+		return
+	}
+	app.SHA256 = sum
+
+	json.NewEncoder(w).Encode(app)
+}
+
+// DetectInstallerType downloads the start of the app's download_url and
+// records the installer type found in the file
+func (h *AppHandler) DetectInstallerType(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	appID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid app ID")
+		return
+	}
+
+	app, err := scanApp(h.db.QueryRow("SELECT "+appColumns+" FROM apps WHERE id = $1", appID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "App not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	if app.UserID != userID {
+		writeErrorResponse(w, http.StatusForbidden, "You can only update your own apps")
+		return
+	}
+
+	if app.DownloadURL == "" || !isValidURL(app.DownloadURL) {
+		writeErrorResponse(w, http.StatusBadRequest, "App has no valid download_url")
+		return
+	}
+
+	installerType, err := utils.SniffInstallerType(app.DownloadURL)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadGateway, "Failed to detect installer type: "+err.Error())
+		return
+	}
+
+	if _, err := h.db.Exec("UPDATE apps SET installer_type = $1 WHERE id = $2", installerType, appID); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update app")
+		return
+	}
+	app.InstallerType = installerType
+
+	json.NewEncoder(w).Encode(app)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"setupforme/bundles"
+	"setupforme/models"
+)
+
+// GetBundles lists the curated bundles with their packages
+func (h *AppHandler) GetBundles(w http.ResponseWriter, r *http.Request) {
+	list, err := bundles.List(h.db)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch bundles")
+		return
+	}
+
+	json.NewEncoder(w).Encode(list)
+}
+
+func (h *AppHandler) GetBundle(w http.ResponseWriter, r *http.Request) {
+	bundle, err := bundles.Get(h.db, r.PathValue("slug"))
+	if err != nil {
+		writeBundleError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// InstallBundle adds a bundle's packages to the user's apps, in the profile
+// given by profile_id or the default profile. Packages whose winget_id is
+// already in the profile are skipped, and packages that fail the checks of a
+// created app are reported as invalid.
+func (h *AppHandler) InstallBundle(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	bundle, err := bundles.Get(h.db, r.PathValue("slug"))
+	if err != nil {
+		writeBundleError(w, err)
+		return
+	}
+
+	reqs := make([]models.CreateAppRequest, 0, len(bundle.Packages))
+	for _, pkg := range bundle.Packages {
+		reqs = append(reqs, models.CreateAppRequest{
+			Name:     pkg.Name,
+			WingetID: pkg.WingetID,
+			Args:     pkg.Args,
+		})
+	}
+
+	reqs, invalid := validateImports(reqs)
+
+	result, err := h.importApps(userID, profileID, reqs, nil)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to install bundle")
+		return
+	}
+	result.Invalid = append(result.Invalid, invalid...)
+
+	response := models.SuccessResponse{
+		Message: "Bundle installed successfully",
+		Data:    result,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateBundle adds a curated bundle (admins only)
+func (h *AppHandler) CreateBundle(w http.ResponseWriter, r *http.Request) {
+	var req models.BundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := bundles.Validate(&req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := bundles.CheckPackages(h.source, &req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	bundle, err := bundles.Create(h.db, req)
+	if err != nil {
+		writeBundleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// UpdateBundle replaces a curated bundle, including its slug (admins only)
+func (h *AppHandler) UpdateBundle(w http.ResponseWriter, r *http.Request) {
+	var req models.BundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if msg := bundles.Validate(&req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+	if msg := bundles.CheckPackages(h.source, &req); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	bundle, err := bundles.Update(h.db, r.PathValue("slug"), req)
+	if err != nil {
+		writeBundleError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// DeleteBundle removes a curated bundle (admins only). Apps installed from it
+// are kept.
+func (h *AppHandler) DeleteBundle(w http.ResponseWriter, r *http.Request) {
+	if err := bundles.Delete(h.db, r.PathValue("slug")); err != nil {
+		writeBundleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBundleError maps bundle store errors to status codes
+func writeBundleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, bundles.ErrNotFound):
+		writeErrorResponse(w, http.StatusNotFound, "Bundle not found")
+	case errors.Is(err, bundles.ErrSlugTaken):
+		writeErrorResponse(w, http.StatusConflict, "A bundle with this slug already exists")
+	default:
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+// maxImportSize bounds uploaded import documents
+const maxImportSize = 1 << 20
+
+// ExportConfiguration renders the user's winget apps as a WinGet Configuration
+// (configuration.dsc.yaml) document for `winget configure`
+func (h *AppHandler) ExportConfiguration(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	apps, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+
+	cfg := utils.DSCConfiguration{
+		Properties: utils.DSCProperties{
+			ConfigurationVersion: utils.DSCConfigurationVersion,
+			Resources:            []utils.DSCResource{},
+		},
+	}
+
+	// Resource ids are the winget ids, so dependencies map onto dependsOn
+	resourceIDs := make(map[int]string)
+	for _, app := range apps {
+		if app.WingetID != "" {
+			resourceIDs[app.ID] = app.WingetID
+		}
+	}
+
+	var comments []string
+	emitted := make(map[string]bool)
+	for _, app := range sortByDependencies(apps) {
+		if app.WingetID == "" {
+			comments = append(comments, "Skipped "+displayName(app)+": no winget_id")
+			continue
+		}
+		if emitted[strings.ToLower(app.WingetID)] {
+			comments = append(comments, "Skipped "+displayName(app)+": duplicate winget_id")
+			continue
+		}
+		emitted[strings.ToLower(app.WingetID)] = true
+
+		var dependsOn []string
+		for _, dep := range app.DependsOn {
+			if id, ok := resourceIDs[dep]; ok {
+				dependsOn = append(dependsOn, id)
+			}
+		}
+
+		var securityContext string
+		if needsAdmin(app) {
+			securityContext = "elevated"
+		}
+
+		cfg.Properties.Resources = append(cfg.Properties.Resources, utils.DSCResource{
+			Resource:  utils.DSCWinGetPackage,
+			ID:        app.WingetID,
+			DependsOn: dependsOn,
+			Directives: utils.DSCDirectives{
+				Description:     displayName(app),
+				AllowPrerelease: utils.IsPrereleaseVersion(app.Version),
+				SecurityContext: securityContext,
+				SetupForMeArgs:  app.Args,
+			},
+			Settings: utils.DSCPackageSettings{
+				ID:      app.WingetID,
+				Source:  utils.SourceForID(app.WingetID),
+				Version: app.Version,
+			},
+		})
+	}
+
+	body, err := utils.RenderConfiguration(cfg, comments)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to render configuration")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="configuration.dsc.yaml"`)
+	w.Write(body)
+}
+
+// ImportConfiguration creates apps from an uploaded WinGet Configuration document
+func (h *AppHandler) ImportConfiguration(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+	if err != nil || len(data) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(data) > maxImportSize {
+		writeErrorResponse(w, http.StatusRequestEntityTooLarge, "Configuration document is too large")
+		return
+	}
+
+	packages, skipped, problems, err := utils.ParseConfiguration(data)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(problems) > 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "Configuration failed validation: "+strings.Join(problems, "; "))
+		return
+	}
+
+	if msg := configurationCycle(packages); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	var reqs []models.CreateAppRequest
+	dependsOn := make(map[string][]string)
+	for _, pkg := range packages {
+		reqs = append(reqs, models.CreateAppRequest{
+			Name:     pkg.Name,
+			WingetID: pkg.WingetID,
+			Version:  pkg.Version,
+			Args:     pkg.Args,
+
+			RequiresAdmin: pkg.RequiresAdmin,
+		})
+		if len(pkg.DependsOn) > 0 {
+			dependsOn[strings.ToLower(pkg.WingetID)] = pkg.DependsOn
+		}
+	}
+
+	reqs, invalid := validateImports(reqs)
+
+	result, err := h.importApps(userID, profileID, reqs, dependsOn)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to import apps")
+		return
+	}
+	result.Invalid = append(result.Invalid, invalid...)
+	for _, s := range skipped {
+		result.Skipped = append(result.Skipped, models.ImportIssue{Entry: s.Entry, Reason: s.Reason})
+	}
+
+	response := models.SuccessResponse{
+		Message: "Configuration imported successfully",
+		Data:    result,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// configurationCycle checks the dependsOn of imported packages for a cycle.
+// It returns a client-facing message naming the cycle, or "".
+func configurationCycle(packages []utils.DSCPackage) string {
+	index := make(map[string]int, len(packages))
+	for i, pkg := range packages {
+		index[strings.ToLower(pkg.WingetID)] = i
+	}
+
+	graph := make(map[int][]int, len(packages))
+	for i, pkg := range packages {
+		graph[i] = nil
+		for _, dep := range pkg.DependsOn {
+			graph[i] = append(graph[i], index[strings.ToLower(dep)])
+		}
+	}
+
+	cycle := findCycle(graph)
+	if cycle == nil {
+		return ""
+	}
+	parts := make([]string, len(cycle))
+	for i, n := range cycle {
+		parts[i] = packages[n].WingetID
+	}
+	return "Configuration failed validation: dependsOn cycle " + strings.Join(parts, " -> ")
+}
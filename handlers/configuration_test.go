@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"testing"
+
+	"setupforme/utils"
+)
+
+func TestConfigurationCycle(t *testing.T) {
+	packages := []utils.DSCPackage{
+		{WingetID: "A.App", DependsOn: []string{"B.App"}},
+		{WingetID: "B.App", DependsOn: []string{"c.app"}},
+		{WingetID: "C.App"},
+	}
+	if msg := configurationCycle(packages); msg != "" {
+		t.Errorf("acyclic packages reported a cycle: %s", msg)
+	}
+
+	packages[2].DependsOn = []string{"A.App"}
+	want := "Configuration failed validation: dependsOn cycle A.App -> B.App -> C.App -> A.App"
+	if msg := configurationCycle(packages); msg != want {
+		t.Errorf("configurationCycle = %q, want %q", msg, want)
+	}
+}
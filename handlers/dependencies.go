@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"setupforme/models"
+)
+
+// loadDependencies returns the dependency edges between a user's apps,
+// keyed by the dependent app's ID
+func (h *AppHandler) loadDependencies(userID int) (map[int][]int, error) {
+	rows, err := h.db.Query(`
+		SELECT d.app_id, d.depends_on_id
+		FROM app_dependencies d
+		JOIN apps a ON a.id = d.app_id
+		WHERE a.user_id = $1
+		ORDER BY d.app_id, d.depends_on_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deps := make(map[int][]int)
+	for rows.Next() {
+		var appID, dependsOnID int
+		if err := rows.Scan(&appID, &dependsOnID); err != nil {
+			return nil, err
+		}
+		deps[appID] = append(deps[appID], dependsOnID)
+	}
+
+	return deps, rows.Err()
+}
+
+// checkDependencies verifies that deps refer to other apps in the profile and
+// that giving appID (0 for a new app) these prerequisites keeps the graph
+// acyclic. It returns a client-facing message, or "" when the dependencies
+// are valid.
+func (h *AppHandler) checkDependencies(userID, profileID, appID int, deps []int) (string, error) {
+	if len(deps) == 0 {
+		return "", nil
+	}
+
+	apps, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[int]string, len(apps))
+	graph := make(map[int][]int, len(apps))
+	for _, app := range apps {
+		names[app.ID] = displayName(app)
+		graph[app.ID] = app.DependsOn
+	}
+
+	for _, dep := range deps {
+		if dep == appID {
+			return "An app cannot depend on itself", nil
+		}
+		if _, ok := names[dep]; !ok {
+			return fmt.Sprintf("depends_on references unknown app %d (apps can only depend on apps in the same profile)", dep), nil
+		}
+	}
+
+	// A new app has no dependents yet, so it cannot close a cycle
+	if appID == 0 {
+		return "", nil
+	}
+
+	graph[appID] = deps
+	return describeCycle(graph, names), nil
+}
+
+// describeCycle returns a client-facing message naming a cycle in graph, or
+// "" when the graph is acyclic
+func describeCycle(graph map[int][]int, names map[int]string) string {
+	cycle := findCycle(graph)
+	if cycle == nil {
+		return ""
+	}
+
+	parts := make([]string, len(cycle))
+	for i, id := range cycle {
+		parts[i] = fmt.Sprintf("%s (%d)", names[id], id)
+	}
+	return "Dependency cycle: " + strings.Join(parts, " -> ")
+}
+
+// saveDependencies replaces the prerequisites of appID
+func saveDependencies(tx *sql.Tx, appID int, deps []int) error {
+	if _, err := tx.Exec("DELETE FROM app_dependencies WHERE app_id = $1", appID); err != nil {
+		return err
+	}
+
+	for _, dep := range deps {
+		_, err := tx.Exec("INSERT INTO app_dependencies (app_id, depends_on_id) VALUES ($1, $2)", appID, dep)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeDependencies sorts and de-duplicates dependency IDs
+func normalizeDependencies(deps []int) []int {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	seen := make(map[int]bool, len(deps))
+	var out []int
+	for _, dep := range deps {
+		if !seen[dep] {
+			seen[dep] = true
+			out = append(out, dep)
+		}
+	}
+	sort.Ints(out)
+
+	return out
+}
+
+// findCycle returns one cycle in graph as a path of IDs that starts and ends
+// with the same node, or nil if the graph is acyclic
+func findCycle(graph map[int][]int) []int {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	nodes := make([]int, 0, len(graph))
+	for id := range graph {
+		nodes = append(nodes, id)
+	}
+	sort.Ints(nodes)
+
+	state := make(map[int]int, len(graph))
+	var stack []int
+
+	var visit func(id int) []int
+	visit = func(id int) []int {
+		state[id] = inProgress
+		stack = append(stack, id)
+
+		for _, next := range graph[id] {
+			switch state[next] {
+			case inProgress:
+				// Cut the stack back to where the cycle starts
+				for i, n := range stack {
+					if n == next {
+						return append(append([]int{}, stack[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range nodes {
+		if state[id] == unvisited {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortByDependencies orders apps so that prerequisites come before the apps
+// that need them. Otherwise the input order is kept. Any apps left over by a
+// cycle are appended in their original order.
+func sortByDependencies(apps []models.App) []models.App {
+	index := make(map[int]int, len(apps))
+	for i, app := range apps {
+		index[app.ID] = i
+	}
+
+	placed := make([]bool, len(apps))
+	sorted := make([]models.App, 0, len(apps))
+
+	// Repeatedly take the first app whose prerequisites are all placed
+	for len(sorted) < len(apps) {
+		progressed := false
+		for i, app := range apps {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, dep := range app.DependsOn {
+				if j, ok := index[dep]; ok && !placed[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[i] = true
+				sorted = append(sorted, app)
+				progressed = true
+				break
+			}
+		}
+
+		if !progressed {
+			for i, app := range apps {
+				if !placed[i] {
+					placed[i] = true
+					sorted = append(sorted, app)
+				}
+			}
+		}
+	}
+
+	return sorted
+}
+
+// processingOrder sorts apps for a script of the given mode: prerequisites
+// first, or for uninstalls dependents first, before the apps they rely on
+func processingOrder(apps []models.App, mode string) []models.App {
+	apps = sortByDependencies(apps)
+	if mode == modeUninstall {
+		for i, j := 0, len(apps)-1; i < j; i, j = i+1, j-1 {
+			apps[i], apps[j] = apps[j], apps[i]
+		}
+	}
+	return apps
+}
+
+// dependencyStages groups apps, already in processing order, into stages whose
+// members only wait on apps in earlier stages. With reverse set dependents are
+// processed before their prerequisites, as uninstalls need.
+func dependencyStages(apps []models.App, reverse bool) [][]models.App {
+	index := make(map[int]int, len(apps))
+	for i, app := range apps {
+		index[app.ID] = i
+	}
+
+	// edges[i] lists the apps that must finish before apps[i] starts
+	edges := make([][]int, len(apps))
+	for i, app := range apps {
+		for _, dep := range app.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			if reverse {
+				edges[j] = append(edges[j], i)
+			} else {
+				edges[i] = append(edges[i], j)
+			}
+		}
+	}
+
+	// An app's stage is one past the latest stage of the apps it waits on,
+	// which the processing order has already visited
+	level := make([]int, len(apps))
+	var stages [][]models.App
+	for i := range apps {
+		for _, j := range edges[i] {
+			if level[j]+1 > level[i] {
+				level[i] = level[j] + 1
+			}
+		}
+		for len(stages) <= level[i] {
+			stages = append(stages, nil)
+		}
+		stages[level[i]] = append(stages[level[i]], apps[i])
+	}
+
+	return stages
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"setupforme/models"
+)
+
+func TestDescribeCycle(t *testing.T) {
+	names := map[int]string{1: "Git", 2: "VS Code", 3: "Python"}
+	tests := []struct {
+		name  string
+		graph map[int][]int
+		want  string
+	}{
+		{"no dependencies", map[int][]int{1: nil, 2: nil, 3: nil}, ""},
+		{"chain", map[int][]int{1: nil, 2: {1}, 3: {2}}, ""},
+		{"diamond", map[int][]int{1: nil, 2: {1}, 3: {1, 2}}, ""},
+		{"self", map[int][]int{1: {1}}, "Dependency cycle: Git (1) -> Git (1)"},
+		{"two apps", map[int][]int{1: {2}, 2: {1}}, "Dependency cycle: Git (1) -> VS Code (2) -> Git (1)"},
+		{"three apps", map[int][]int{1: {3}, 2: {1}, 3: {2}}, "Dependency cycle: Git (1) -> Python (3) -> VS Code (2) -> Git (1)"},
+		{"cycle behind a prerequisite", map[int][]int{1: {2}, 2: {3}, 3: {2}}, "Dependency cycle: VS Code (2) -> Python (3) -> VS Code (2)"},
+	}
+
+	for _, tt := range tests {
+		if got := describeCycle(tt.graph, names); got != tt.want {
+			t.Errorf("%s: describeCycle = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// ids lists the app IDs of apps, or of each stage of apps
+func ids(apps []models.App) []int {
+	out := make([]int, len(apps))
+	for i, app := range apps {
+		out[i] = app.ID
+	}
+	return out
+}
+
+func stageIDs(stages [][]models.App) [][]int {
+	out := make([][]int, len(stages))
+	for i, stage := range stages {
+		out[i] = ids(stage)
+	}
+	return out
+}
+
+func TestDependencyOrdering(t *testing.T) {
+	tests := []struct {
+		name      string
+		apps      []models.App
+		install   []int
+		uninstall []int
+		stages    [][]int // install stages
+		reverse   [][]int // uninstall stages
+	}{
+		{
+			name:      "independent apps keep their order",
+			apps:      []models.App{{ID: 1}, {ID: 2}, {ID: 3}},
+			install:   []int{1, 2, 3},
+			uninstall: []int{3, 2, 1},
+			stages:    [][]int{{1, 2, 3}},
+			reverse:   [][]int{{3, 2, 1}},
+		},
+		{
+			name:      "prerequisite listed last",
+			apps:      []models.App{{ID: 1, DependsOn: []int{3}}, {ID: 2}, {ID: 3}},
+			install:   []int{2, 3, 1},
+			uninstall: []int{1, 3, 2},
+			stages:    [][]int{{2, 3}, {1}},
+			reverse:   [][]int{{1, 2}, {3}},
+		},
+		{
+			name:      "chain",
+			apps:      []models.App{{ID: 3, DependsOn: []int{2}}, {ID: 2, DependsOn: []int{1}}, {ID: 1}},
+			install:   []int{1, 2, 3},
+			uninstall: []int{3, 2, 1},
+			stages:    [][]int{{1}, {2}, {3}},
+			reverse:   [][]int{{3}, {2}, {1}},
+		},
+		{
+			name:      "diamond",
+			apps:      []models.App{{ID: 4, DependsOn: []int{2, 3}}, {ID: 2, DependsOn: []int{1}}, {ID: 3, DependsOn: []int{1}}, {ID: 1}},
+			install:   []int{1, 2, 3, 4},
+			uninstall: []int{4, 3, 2, 1},
+			stages:    [][]int{{1}, {2, 3}, {4}},
+			reverse:   [][]int{{4}, {3, 2}, {1}},
+		},
+		{
+			name:      "dependency outside the list is ignored",
+			apps:      []models.App{{ID: 1, DependsOn: []int{99}}, {ID: 2}},
+			install:   []int{1, 2},
+			uninstall: []int{2, 1},
+			stages:    [][]int{{1, 2}},
+			reverse:   [][]int{{2, 1}},
+		},
+		{
+			name:      "apps in a cycle are appended and run one by one",
+			apps:      []models.App{{ID: 1, DependsOn: []int{2}}, {ID: 2, DependsOn: []int{1}}, {ID: 3}},
+			install:   []int{3, 1, 2},
+			uninstall: []int{2, 1, 3},
+			stages:    [][]int{{3}, {1}, {2}},
+			reverse:   [][]int{{3}, {2}, {1}},
+		},
+	}
+
+	for _, tt := range tests {
+		install := processingOrder(append([]models.App{}, tt.apps...), modeInstall)
+		if got := ids(install); !reflect.DeepEqual(got, tt.install) {
+			t.Errorf("%s: install order = %v, want %v", tt.name, got, tt.install)
+		}
+		uninstall := processingOrder(append([]models.App{}, tt.apps...), modeUninstall)
+		if got := ids(uninstall); !reflect.DeepEqual(got, tt.uninstall) {
+			t.Errorf("%s: uninstall order = %v, want %v", tt.name, got, tt.uninstall)
+		}
+
+		if got := stageIDs(dependencyStages(install, false)); !reflect.DeepEqual(got, tt.stages) {
+			t.Errorf("%s: install stages = %v, want %v", tt.name, got, tt.stages)
+		}
+		if got := stageIDs(dependencyStages(uninstall, true)); !reflect.DeepEqual(got, tt.reverse) {
+			t.Errorf("%s: uninstall stages = %v, want %v", tt.name, got, tt.reverse)
+		}
+	}
+}
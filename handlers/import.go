@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"setupforme/models"
+)
+
+// validateImports runs validateAppRequest on each entry of an import. It
+// returns the entries that passed and an issue for each one that did not, so
+// one bad entry does not fail the whole import.
+func validateImports(reqs []models.CreateAppRequest) ([]models.CreateAppRequest, []models.ImportIssue) {
+	valid := make([]models.CreateAppRequest, 0, len(reqs))
+	var invalid []models.ImportIssue
+	for _, req := range reqs {
+		if msg := validateAppRequest(&req); msg != "" {
+			entry := req.WingetID
+			if entry == "" {
+				entry = req.Name
+			}
+			invalid = append(invalid, models.ImportIssue{Entry: entry, Reason: msg})
+			continue
+		}
+		valid = append(valid, req)
+	}
+	return valid, invalid
+}
+
+// importApps bulk-creates apps in one of a user's profiles inside a single
+// transaction. Entries whose winget_id already exists in the profile (or
+// earlier in the batch) are skipped rather than duplicated. dependsOn maps a
+// lowercased winget_id to the winget_ids it depends on; those dependencies are
+// saved for the created apps, pointing at the created or existing app with
+// that winget_id. The caller makes sure they form no cycle. Package metadata
+// of the created apps is looked up in the background after the import.
+func (h *AppHandler) importApps(userID, profileID int, reqs []models.CreateAppRequest, dependsOn map[string][]string) (models.ImportResult, error) {
+	result := models.ImportResult{
+		Created: []models.App{},
+		Skipped: []models.ImportIssue{},
+		Invalid: []models.ImportIssue{},
+	}
+
+	existing, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		return result, err
+	}
+
+	// App IDs by lowercased winget_id, for dependencies and de-duplication
+	seen := make(map[string]int)
+	for _, app := range existing {
+		if app.WingetID != "" {
+			seen[strings.ToLower(app.WingetID)] = app.ID
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, req := range reqs {
+		key := strings.ToLower(req.WingetID)
+		if _, ok := seen[key]; key != "" && ok {
+			result.Skipped = append(result.Skipped, models.ImportIssue{Entry: req.WingetID, Reason: "already in this profile"})
+			continue
+		}
+
+		var appID int
+		err := tx.QueryRow(`
+			INSERT INTO apps (user_id, profile_id, name, winget_id, winget_id_resolution, download_url, args, version, requires_admin)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)
+			RETURNING id
+		`, userID, profileID, req.Name, req.WingetID, wingetIDResolution(req.WingetID, resolutionConfirmed),
+			req.DownloadURL, req.Args, req.Version, req.RequiresAdmin).Scan(&appID)
+		if err != nil {
+			return result, err
+		}
+
+		if key != "" {
+			seen[key] = appID
+		}
+
+		result.Created = append(result.Created, models.App{
+			ID:          appID,
+			UserID:      userID,
+			ProfileID:   profileID,
+			Name:        req.Name,
+			WingetID:    req.WingetID,
+			DownloadURL: req.DownloadURL,
+			Args:        req.Args,
+			Version:     req.Version,
+
+			RequiresAdmin:      req.RequiresAdmin,
+			WingetIDResolution: wingetIDResolution(req.WingetID, resolutionConfirmed),
+		})
+	}
+
+	for i, app := range result.Created {
+		var deps []int
+		for _, dep := range dependsOn[strings.ToLower(app.WingetID)] {
+			if id, ok := seen[strings.ToLower(dep)]; ok && id != app.ID {
+				deps = append(deps, id)
+			}
+		}
+		deps = normalizeDependencies(deps)
+		if len(deps) == 0 {
+			continue
+		}
+		if err := saveDependencies(tx, app.ID, deps); err != nil {
+			return result, err
+		}
+		result.Created[i].DependsOn = deps
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+
+	// Lookups can take minutes for a large import, so leave them to a
+	// background refresh instead of holding up the response
+	if len(result.Created) > 0 {
+		go func() {
+			if err := h.refreshMetadata(); err != nil {
+				log.Printf("metadata: refresh after import: %v", err)
+			}
+		}()
+	}
+
+	return result, nil
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"setupforme/models"
+	"setupforme/scriptbuilder"
+)
+
+// psRunStateHelpers persist a run's progress to $StateFile so that another
+// phase (the elevated one, or the pass after a restart) can pick it up
+const psRunStateHelpers = `# Apps with a result from this or an earlier pass, and whether a restart
+# cuts this pass short
+$completedApps = @{}
+$runState = @{ Restart = $false; Deferred = New-Object System.Collections.Generic.List[string] }
+
+# An app whose phase is 'any' runs in whichever phase reaches it first
+function Test-Step { param([int]$Id, [string]$Need)
+  if ($runState.Restart -or $completedApps.ContainsKey($Id)) { return $false }
+  return ($Phase -eq 'all' -or $Need -eq 'any' -or $Phase -eq $Need)
+}
+
+function Save-State {
+  if (-not $StateFile) { return }
+  @{
+    completed = @($completedApps.Keys); failed = @($failedApps.Keys); summary = @($summary)
+    deferred = @($runState.Deferred); restart = $runState.Restart
+  } | ConvertTo-Json -Depth 4 | Set-Content -Path $StateFile -Encoding UTF8
+}
+
+function Import-State {
+  if (-not $StateFile -or -not (Test-Path $StateFile)) { return }
+  $state = Get-Content -Path $StateFile -Raw | ConvertFrom-Json
+  foreach ($id in @($state.completed | Where-Object { $null -ne $_ })) { $completedApps[[int]$id] = $true }
+  foreach ($id in @($state.failed | Where-Object { $null -ne $_ })) { $failedApps[[int]$id] = $true }
+  foreach ($row in @($state.summary | Where-Object { $_ })) { $summary.Add($row) }
+  foreach ($name in @($state.deferred | Where-Object { $_ })) { $runState.Deferred.Add([string]$name) }
+}
+
+# Records an app that needs administrator rights as skipped when the elevated
+# phase did not get to it
+function Skip-AdminStep { param([int]$Id, [string]$Name)
+  if ($Phase -ne 'user' -or $runState.Restart -or $completedApps.ContainsKey($Id)) { return }
+  Write-Host "Skipping ${Name}: administrator rights were not granted" -ForegroundColor DarkYellow
+  $failedApps[$Id] = $true
+  Add-Result $Id $Name 'skipped' 'administrator rights were not granted'
+}
+
+# Handles an app's request for a restart according to its reboot behavior
+function Request-Restart { param([string]$Name, [string]$Behavior, [bool]$Required)
+  if (-not $Required) { return }
+  if ($Behavior -eq 'immediate') {
+    Write-Host "$Name needs a restart before setup can continue" -ForegroundColor Yellow
+    $runState.Restart = $true
+  } else {
+    $runState.Deferred.Add($Name)
+  }
+  Save-State
+}`
+
+// psLauncherSetup picks the state file of this run. A resumed run passes the
+// state file of the run it continues.
+const psLauncherSetup = `if (-not $StateFile) {
+  $stateDir = Join-Path $env:LOCALAPPDATA 'SetupForMe'
+  New-Item -ItemType Directory -Path $stateDir -Force | Out-Null
+  $StateFile = Join-Path $stateDir ('run-' + [guid]::NewGuid().ToString() + '.json')
+}
+$resumeFile = [System.IO.Path]::ChangeExtension($StateFile, '.ps1')
+$adminFile = [System.IO.Path]::ChangeExtension($StateFile, '.admin.ps1')
+$hostExe = (Get-Process -Id $PID).Path
+
+function Read-State {
+  if (-not (Test-Path $StateFile)) { return $null }
+  return Get-Content -Path $StateFile -Raw | ConvertFrom-Json
+}
+
+# A restart requested by the previous pass has happened by now
+$state = Read-State
+if ($state -and $state.restart) {
+  $state.restart = $false
+  $state | ConvertTo-Json -Depth 4 | Set-Content -Path $StateFile -Encoding UTF8
+}`
+
+// psElevatedPhases runs $SetupForMeScript directly when the session is
+// already elevated. Otherwise an elevated copy installs the apps that need
+// administrator rights and those that must come before them (see
+// stepPhases), then the rest run in this (original) window. The
+// UAC prompt is skipped when an earlier pass already finished those apps.
+const psElevatedPhases = `$principal = New-Object Security.Principal.WindowsPrincipal([Security.Principal.WindowsIdentity]::GetCurrent())
+if ($principal.IsInRole([Security.Principal.WindowsBuiltInRole]::Administrator)) {
+  & $SetupForMeScript -StateFile $StateFile
+} else {
+  $done = @()
+  if ($state) { $done = @($state.completed | Where-Object { $null -ne $_ }) }
+  if (@($adminApps | Where-Object { $done -notcontains $_ }).Count -gt 0) {
+    Set-Content -Path $adminFile -Value $SetupForMeScript.ToString() -Encoding UTF8
+    Write-Host 'Some apps need administrator rights. Approve the UAC prompt to install them.' -ForegroundColor Yellow
+    try {
+      $argList = @('-NoProfile', '-ExecutionPolicy', 'Bypass', '-File', ('"' + $adminFile + '"'), '-Phase', 'admin', '-StateFile', ('"' + $StateFile + '"'))
+      Start-Process -FilePath $hostExe -Verb RunAs -Wait -ArgumentList $argList -ErrorAction Stop
+    } catch {
+      Write-Host 'Elevation was declined; apps that need administrator rights will be skipped.' -ForegroundColor DarkYellow
+    }
+  }
+  $state = Read-State
+  if (-not ($state -and $state.restart)) { & $SetupForMeScript -Phase 'user' -StateFile $StateFile }
+}`
+
+// psRestartHandling restarts the computer when an app asked for an immediate
+// restart, after registering a RunOnce entry that resumes the run at the next
+// app. Once every app is done, deferred restarts are offered together.
+const psRestartHandling = `$state = Read-State
+if ($state -and $state.restart) {
+  $nl = [Environment]::NewLine
+  $resume = '$SetupForMeScript = {' + $nl + $SetupForMeScript.ToString() + $nl + '}' + $nl +
+    '$SetupForMeLauncher = {' + $SetupForMeLauncher.ToString() + '}' + $nl +
+    '& $SetupForMeLauncher -StateFile ''' + $StateFile.Replace("'", "''") + ''''
+  Set-Content -Path $resumeFile -Value $resume -Encoding UTF8
+  $runOnce = 'HKCU:\Software\Microsoft\Windows\CurrentVersion\RunOnce'
+  if (-not (Test-Path $runOnce)) { New-Item -Path $runOnce -Force | Out-Null }
+  Set-ItemProperty -Path $runOnce -Name 'SetupForMe' -Value ('"' + $hostExe + '" -NoProfile -ExecutionPolicy Bypass -File "' + $resumeFile + '"')
+  Write-Host 'Restarting in 15 seconds; setup continues with the next app after you sign in again.' -ForegroundColor Yellow
+  Start-Sleep -Seconds 15
+  Restart-Computer -Force
+  return
+}
+
+Remove-Item -Path $StateFile, $resumeFile, $adminFile -Force -ErrorAction SilentlyContinue
+$deferred = @()
+if ($state) { $deferred = @($state.deferred | Where-Object { $_ }) }
+if ($deferred.Count -gt 0) {
+  Write-Host ('A restart is required to finish: ' + ($deferred -join ', ')) -ForegroundColor Yellow
+  Write-Host 'Restarting in 60 seconds; press Ctrl+C to restart later.' -ForegroundColor Yellow
+  Start-Sleep -Seconds 60
+  Restart-Computer -Force
+}`
+
+// psLauncher appends $SetupForMeLauncher, which runs the phases of a wrapped
+// script against one state file and restarts the computer when asked to
+func psLauncher(b *scriptbuilder.Builder, apps []models.App) {
+	b.Raw("$SetupForMeLauncher = {", "  param([string]$StateFile = '')", "")
+	b.Raw(indent(psLauncherSetup, "  "), "")
+
+	if needsElevation(apps) {
+		var ids []int
+		for _, app := range apps {
+			if needsAdmin(app) {
+				ids = append(ids, app.ID)
+			}
+		}
+		b.Line("  $adminApps = %s", ids)
+		b.Raw(indent(psElevatedPhases, "  "))
+	} else {
+		b.Raw("  & $SetupForMeScript -StateFile $StateFile")
+	}
+
+	b.Raw("", indent(psRestartHandling, "  "), "}", "")
+	b.Raw("& $SetupForMeLauncher")
+}
+
+// psStepGuard appends an app's lines, guarded so they are skipped when an
+// earlier pass already processed the app or a restart is pending. In a wrapped
+// script (phases is not nil) the lines also only run in the app's phase, and
+// an app that needs administrator rights is recorded as skipped in the
+// original window if the elevated phase never ran.
+func psStepGuard(b *scriptbuilder.Builder, app models.App, phases map[int]string, lines []string) {
+	if phases == nil {
+		b.Raw(lines...)
+		return
+	}
+
+	phase := phases[app.ID]
+	b.Line("if (Test-Step %s %s) {", app.ID, phase)
+	b.Raw(lines...)
+	if phase == "admin" {
+		b.Line("} else { Skip-AdminStep %s %s }", app.ID, displayName(app))
+	} else {
+		b.Raw("}")
+	}
+}
+
+// stepPhases picks the phase of a wrapped script that processes each app.
+// Apps that need administrator rights run in the elevated admin phase, which
+// comes first, and the rest in the user phase. Apps that have to be processed
+// before an admin app (its prerequisites, or its dependents when
+// uninstalling) get the any phase instead: the elevated pass handles them
+// ahead of the app, and the user phase still does if elevation is declined.
+func stepPhases(apps []models.App, opts scriptOptions) map[int]string {
+	phases := make(map[int]string, len(apps))
+	before := make(map[int][]int) // app ID -> apps that must be processed first
+	for _, app := range apps {
+		phases[app.ID] = "user"
+		for _, dep := range app.DependsOn {
+			if opts.Mode == modeUninstall {
+				before[dep] = append(before[dep], app.ID)
+			} else {
+				before[app.ID] = append(before[app.ID], dep)
+			}
+		}
+	}
+
+	var queue []int
+	for _, app := range apps {
+		if needsAdmin(app) {
+			phases[app.ID] = "admin"
+			queue = append(queue, app.ID)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, first := range before[id] {
+			if phases[first] == "user" {
+				phases[first] = "any"
+				queue = append(queue, first)
+			}
+		}
+	}
+	return phases
+}
+
+// needsAdmin reports whether the app must be processed from an elevated session
+func needsAdmin(app models.App) bool {
+	return app.RequiresAdmin || app.Scope == scopeMachine
+}
+
+// needsElevation reports whether any app needs administrator rights
+func needsElevation(apps []models.App) bool {
+	for _, app := range apps {
+		if needsAdmin(app) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsLauncher reports whether the script has to be wrapped so it can run
+// again, elevated or after a restart
+func needsLauncher(apps []models.App) bool {
+	for _, app := range apps {
+		if needsAdmin(app) || rebootBehavior(app) != rebootIgnore {
+			return true
+		}
+	}
+	return false
+}
+
+// rebootBehavior returns the app's reboot behavior; restarts are ignored
+// unless the app asks otherwise
+func rebootBehavior(app models.App) string {
+	if app.RebootBehavior != "" {
+		return app.RebootBehavior
+	}
+	return rebootIgnore
+}
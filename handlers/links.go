@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+const (
+	defaultLinkTTL = 60 * time.Minute
+	maxLinkTTL     = 7 * 24 * time.Hour
+)
+
+// CreateLink issues a shareable URL that serves the user's script as plain text
+func (h *AppHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	var req models.CreateScriptLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	q := url.Values{}
+	for k, v := range req.Options {
+		q.Set(k, v)
+	}
+	opts, err := parseScriptOptions(q)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Pin the link to a profile so it keeps serving the same apps when the
+	// default profile changes
+	opts.ProfileID, err = h.lookupProfile(userID, opts.ProfileID)
+	if err != nil {
+		if errors.Is(err, errProfileNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	ttl := defaultLinkTTL
+	if req.TTLMinutes < 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "ttl_minutes must be positive")
+		return
+	}
+	if req.TTLMinutes > 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	if ttl > maxLinkTTL {
+		writeErrorResponse(w, http.StatusBadRequest, "ttl_minutes must be at most 10080 (7 days)")
+		return
+	}
+
+	token, tokenHash, err := utils.GenerateToken()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	params := opts.encode()
+	link := models.ScriptLink{
+		URL:       publicBaseURL(r) + "/s/" + token,
+		Options:   flattenValues(params),
+		SingleUse: req.SingleUse,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+		Active:    true,
+	}
+
+	err = h.db.QueryRow(`
+		INSERT INTO script_links (user_id, token_hash, params, single_use, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, tokenHash, params.Encode(), link.SingleUse, link.ExpiresAt).Scan(&link.ID, &link.CreatedAt)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create link")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(link)
+}
+
+// GetLinks lists the user's script links, newest first
+func (h *AppHandler) GetLinks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	rows, err := h.db.Query(`
+		SELECT id, params, single_use, expires_at, revoked_at, access_count, last_accessed_at, created_at
+		FROM script_links WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch links")
+		return
+	}
+	defer rows.Close()
+
+	links := []models.ScriptLink{}
+	now := time.Now()
+	for rows.Next() {
+		var link models.ScriptLink
+		var params string
+		var revokedAt, lastAccessedAt sql.NullTime
+
+		err := rows.Scan(&link.ID, &params, &link.SingleUse, &link.ExpiresAt, &revokedAt,
+			&link.AccessCount, &lastAccessedAt, &link.CreatedAt)
+		if err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to scan link")
+			return
+		}
+
+		values, _ := url.ParseQuery(params)
+		link.Options = flattenValues(values)
+		if revokedAt.Valid {
+			link.RevokedAt = &revokedAt.Time
+		}
+		if lastAccessedAt.Valid {
+			link.LastAccessedAt = &lastAccessedAt.Time
+		}
+		link.Active = !revokedAt.Valid && link.ExpiresAt.After(now) && !(link.SingleUse && link.AccessCount > 0)
+
+		links = append(links, link)
+	}
+
+	json.NewEncoder(w).Encode(links)
+}
+
+// RevokeLink disables a script link immediately
+func (h *AppHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	linkID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid link ID")
+		return
+	}
+
+	// Check if link exists and belongs to user
+	var existingUserID int
+	err = h.db.QueryRow("SELECT user_id FROM script_links WHERE id = $1", linkID).Scan(&existingUserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Link not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	if existingUserID != userID {
+		writeErrorResponse(w, http.StatusForbidden, "You can only revoke your own links")
+		return
+	}
+
+	_, err = h.db.Exec("UPDATE script_links SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1", linkID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to revoke link")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeLink returns the raw script behind a link so it can be piped into a
+// shell, e.g. `irm https://host/s/<token> | iex`. No login is required.
+func (h *AppHandler) ServeLink(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	// The access is counted in a transaction that only commits once the script
+	// has rendered, so a failure does not spend a single-use link. The updated
+	// row stays locked until then, which keeps concurrent requests from using
+	// a single-use link twice.
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Count the access and enforce expiry, revocation and single use in one statement
+	var userID int
+	var params string
+	err = tx.QueryRow(`
+		UPDATE script_links
+		SET access_count = access_count + 1, last_accessed_at = NOW()
+		WHERE token_hash = $1
+			AND revoked_at IS NULL
+			AND expires_at > NOW()
+			AND NOT (single_use AND access_count > 0)
+		RETURNING user_id, params
+	`, utils.HashToken(token)).Scan(&userID, &params)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Link not found or expired")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	values, _ := url.ParseQuery(params)
+	opts, err := parseScriptOptions(values)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Stored link options are invalid")
+		return
+	}
+
+	script, err := h.renderScript(userID, opts, publicBaseURL(r))
+	if errors.Is(err, errProfileNotFound) {
+		writeErrorResponse(w, http.StatusNotFound, "Link not found or expired")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate script")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(script))
+}
+
+// publicBaseURL returns the externally visible origin of this server.
+// PUBLIC_URL wins; otherwise it is derived from the request.
+func publicBaseURL(r *http.Request) string {
+	if base := strings.TrimRight(os.Getenv("PUBLIC_URL"), "/"); base != "" {
+		return base
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// flattenValues keeps the first value of each query parameter
+func flattenValues(values url.Values) map[string]string {
+	m := make(map[string]string, len(values))
+	for k := range values {
+		m[k] = values.Get(k)
+	}
+	return m
+}
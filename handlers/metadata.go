@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+// How often the background job looks for package metadata to refresh, how
+// old metadata may get, how long to wait before retrying a failed lookup, and
+// how many apps one pass refreshes at most
+const (
+	metadataRefreshInterval = time.Hour
+	metadataMaxAge          = 24 * time.Hour
+	metadataRetryDelay      = 6 * time.Hour
+	metadataRefreshBatch    = 200
+)
+
+// metadataColumns lists the package metadata columns in the order expected by
+// nullMetadata.dest
+const metadataColumns = `publisher, homepage, license, description, icon_url, latest_version, enriched_at`
+
+// nullMetadata scans the nullable package metadata columns
+type nullMetadata struct {
+	publisher, homepage, license, description, iconURL, latestVersion sql.NullString
+	enrichedAt                                                        sql.NullTime
+}
+
+func (m *nullMetadata) dest() []interface{} {
+	return []interface{}{&m.publisher, &m.homepage, &m.license, &m.description, &m.iconURL, &m.latestVersion, &m.enrichedAt}
+}
+
+func (m *nullMetadata) value() models.PackageMetadata {
+	meta := models.PackageMetadata{
+		Publisher:     m.publisher.String,
+		Homepage:      m.homepage.String,
+		License:       m.license.String,
+		Description:   m.description.String,
+		IconURL:       m.iconURL.String,
+		LatestVersion: m.latestVersion.String,
+	}
+	if m.enrichedAt.Valid {
+		meta.EnrichedAt = &m.enrichedAt.Time
+	}
+	return meta
+}
+
+// metadataFromPackage picks the metadata stored on apps from a package
+func metadataFromPackage(pkg utils.WingetPackage) models.PackageMetadata {
+	now := time.Now()
+	meta := models.PackageMetadata{
+		Publisher:   pkg.Latest.Publisher,
+		Homepage:    pkg.Latest.Homepage,
+		License:     pkg.Latest.License,
+		Description: pkg.Latest.Description,
+		IconURL:     pkg.IconUrl,
+		EnrichedAt:  &now,
+	}
+	if len(pkg.Versions) > 0 {
+		meta.LatestVersion = pkg.Versions[0]
+	}
+	return meta
+}
+
+// storeMetadata saves an app's package metadata, provided its winget_id is
+// still the one the metadata was looked up for
+func (h *AppHandler) storeMetadata(appID int, wingetID string, meta models.PackageMetadata) error {
+	_, err := h.db.Exec(`
+		UPDATE apps SET publisher = $1, homepage = $2, license = $3, description = $4, icon_url = $5,
+			latest_version = $6, enriched_at = $7, metadata_attempted_at = NULL
+		WHERE id = $8 AND COALESCE(winget_id, '') = $9
+	`, meta.Publisher, meta.Homepage, meta.License, meta.Description, meta.IconURL, meta.LatestVersion,
+		meta.EnrichedAt, appID, wingetID)
+	return err
+}
+
+// enrichApp replaces an app's package metadata after its winget_id was set or
+// changed. Metadata that cannot be looked up now is left to the background
+// refresh, so this never fails the request that changed the app.
+func (h *AppHandler) enrichApp(appID int, wingetID string) models.PackageMetadata {
+	var meta models.PackageMetadata
+	if wingetID != "" {
+		if pkg, err := h.packageDetails(wingetID); err == nil {
+			meta = metadataFromPackage(pkg)
+		} else if !errors.Is(err, errNotInSource) {
+			log.Printf("metadata: looking up %s for app %d: %v", wingetID, appID, err)
+		}
+	}
+
+	if err := h.storeMetadata(appID, wingetID, meta); err != nil {
+		log.Printf("metadata: saving app %d: %v", appID, err)
+	}
+	return meta
+}
+
+// StartMetadataRefresh keeps the package metadata of stored apps current in
+// the background, starting with apps that have none yet
+func (h *AppHandler) StartMetadataRefresh() {
+	go func() {
+		for {
+			if err := h.refreshMetadata(); err != nil {
+				log.Printf("metadata: refresh: %v", err)
+			}
+			time.Sleep(metadataRefreshInterval)
+		}
+	}()
+}
+
+// refreshMetadata looks up the packages of apps whose metadata is missing or
+// older than metadataMaxAge. A package that is no longer found keeps its last
+// known metadata. A failed lookup is recorded in metadata_attempted_at and
+// not retried for metadataRetryDelay, so failing apps cannot fill every batch.
+func (h *AppHandler) refreshMetadata() error {
+	now := time.Now()
+	rows, err := h.db.Query(`
+		SELECT id, winget_id
+		FROM apps
+		WHERE winget_id <> '' AND (enriched_at IS NULL OR enriched_at < $1)
+			AND (metadata_attempted_at IS NULL OR metadata_attempted_at < $2)
+		ORDER BY enriched_at NULLS FIRST
+		LIMIT $3
+	`, now.Add(-metadataMaxAge), now.Add(-metadataRetryDelay), metadataRefreshBatch)
+	if err != nil {
+		return err
+	}
+
+	type staleApp struct {
+		id       int
+		wingetID string
+	}
+	var apps []staleApp
+	for rows.Next() {
+		var app staleApp
+		if err := rows.Scan(&app.id, &app.wingetID); err != nil {
+			rows.Close()
+			return err
+		}
+		apps = append(apps, app)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		pkg, err := h.packageDetails(app.wingetID)
+		switch {
+		case err == nil:
+			err = h.storeMetadata(app.id, app.wingetID, metadataFromPackage(pkg))
+		case errors.Is(err, utils.ErrPackageNotFound), errors.Is(err, errNotInSource):
+			_, err = h.db.Exec("UPDATE apps SET enriched_at = NOW() WHERE id = $1", app.id)
+		case errors.Is(err, utils.ErrSourceUnavailable):
+			// Try again on the next pass instead of failing every remaining lookup
+			return err
+		default:
+			log.Printf("metadata: looking up %s for app %d: %v", app.wingetID, app.id, err)
+			_, err = h.db.Exec("UPDATE apps SET metadata_attempted_at = NOW() WHERE id = $1", app.id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
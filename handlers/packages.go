@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+// ExportPackages renders the user's winget apps as a packages.json document
+// that `winget import` can consume directly
+func (h *AppHandler) ExportPackages(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	apps, err := h.fetchApps(userID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+
+	var entries []utils.WingetPackagesEntry
+	for _, app := range apps {
+		if app.WingetID == "" {
+			continue
+		}
+		entries = append(entries, utils.WingetPackagesEntry{
+			PackageIdentifier: app.WingetID,
+			Version:           app.Version,
+		})
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="packages.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(utils.NewWingetPackagesFile(entries))
+}
+
+// ImportPackages creates apps from an uploaded `winget export` packages.json
+func (h *AppHandler) ImportPackages(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.requestedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+	if err != nil || len(data) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(data) > maxImportSize {
+		writeErrorResponse(w, http.StatusRequestEntityTooLarge, "Packages file is too large")
+		return
+	}
+
+	entries, invalid, err := utils.ParseWingetPackages(data)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var reqs []models.CreateAppRequest
+	for _, entry := range entries {
+		reqs = append(reqs, models.CreateAppRequest{
+			Name:     entry.PackageIdentifier,
+			WingetID: entry.PackageIdentifier,
+			Version:  entry.Version,
+		})
+	}
+
+	reqs, rejected := validateImports(reqs)
+
+	result, err := h.importApps(userID, profileID, reqs, nil)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to import apps")
+		return
+	}
+	for _, inv := range invalid {
+		result.Invalid = append(result.Invalid, models.ImportIssue{Entry: inv.Entry, Reason: inv.Reason})
+	}
+	result.Invalid = append(result.Invalid, rejected...)
+
+	response := models.SuccessResponse{
+		Message: "Packages imported successfully",
+		Data:    result,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
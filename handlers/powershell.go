@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"setupforme/models"
+	"setupforme/scriptbuilder"
+	"setupforme/utils"
+)
+
+// psInstallHelpers are the functions that act on a single app. They are
+// wrapped in $SetupForMeHelpers so background jobs can load them too.
+// Each helper records the last process exit code with Set-ExitCode.
+const psInstallHelpers = `# Records an exit code and whether it asks for a restart: 1641 / 3010 from
+# installers, 0x8A150109 / 0x8A15010B from winget
+function Set-ExitCode { param($Code)
+  $global:SetupForMeExitCode = $Code
+  $global:SetupForMeRebootRequired = $Code -in 1641, 3010, -1978334967, -1978334965
+}
+
+# Quotes each argument the way the C runtime splits a command line. NAME=value
+# pairs keep the NAME="value" form that msiexec and most installers expect.
+function ConvertTo-CommandLine { param([string[]]$Arguments)
+  $quoted = foreach ($a in $Arguments) {
+    if ($a -ne '' -and $a -notmatch '[\s"]') { $a; continue }
+    $prefix = ''
+    if ($a -match '^([/-]?[A-Za-z_][\w.]*=)(.*)$') { $prefix = $Matches[1]; $a = $Matches[2] }
+    $a = $a -replace '(\\*)"', '$1$1\"' -replace '(\\+)$', '$1$1'
+    $prefix + '"' + $a + '"'
+  }
+  return ($quoted -join ' ')
+}
+
+function Test-WingetInstalled { param([string]$Id)
+  $null = & winget list -e --id $Id --accept-source-agreements 2>&1
+  return ($LASTEXITCODE -eq 0)
+}
+
+# Installs, upgrades or skips a package according to $Policy (skip, upgrade, force)
+function Install-WingetApp { param([string]$Id, [string[]]$InstallerArgs, [string]$Version, [string]$Policy = 'skip', [string]$Scope)
+  Set-ExitCode $null
+  $verb = 'install'
+  if ($Policy -ne 'force' -and (Test-WingetInstalled $Id)) {
+    if ($Policy -eq 'skip') {
+      Write-Host "$Id is already installed, skipping" -ForegroundColor DarkGray
+      return 'skipped (already installed)'
+    }
+    $verb = 'upgrade'
+  }
+  $argList = @($verb, '-e', '--id', $Id, '--accept-source-agreements', '--accept-package-agreements')
+  if ($Version) { $argList += @('--version', $Version) }
+  if ($Scope) { $argList += @('--scope', $Scope) }
+  if ($Policy -eq 'force') { $argList += '--force' }
+  if ($InstallerArgs) { $argList += $InstallerArgs }
+  $commandLine = ConvertTo-CommandLine $argList
+  Write-Host "winget $commandLine" -ForegroundColor Cyan
+  $p = Start-Process 'winget' -ArgumentList $commandLine -Wait -NoNewWindow -PassThru
+  Set-ExitCode $p.ExitCode
+  # 0x8A15002B: no applicable upgrade, 0x8A150061: already installed
+  if ($p.ExitCode -in -1978335189, -1978335135) { return 'up to date' }
+  if ($p.ExitCode -ne 0 -and -not $global:SetupForMeRebootRequired) { throw "winget exited with code $($p.ExitCode)" }
+  if ($verb -eq 'upgrade') { return 'upgraded' }
+  if ($Policy -eq 'force') { return 'reinstalled' }
+  return 'installed'
+}
+
+function Update-WingetApp { param([string]$Id, [string[]]$InstallerArgs, [string]$Version, [string]$Scope)
+  Set-ExitCode $null
+  if (-not (Test-WingetInstalled $Id)) {
+    Write-Host "$Id is not installed, skipping" -ForegroundColor DarkGray
+    return 'skipped (not installed)'
+  }
+  return Install-WingetApp $Id $InstallerArgs $Version 'upgrade' $Scope
+}
+
+function Uninstall-WingetApp { param([string]$Id, [string]$Scope)
+  Set-ExitCode $null
+  if (-not (Test-WingetInstalled $Id)) {
+    Write-Host "$Id is not installed, skipping" -ForegroundColor DarkGray
+    return 'skipped (not installed)'
+  }
+  $argList = @('uninstall', '-e', '--id', $Id, '--silent', '--accept-source-agreements')
+  if ($Scope) { $argList += @('--scope', $Scope) }
+  $commandLine = ConvertTo-CommandLine $argList
+  Write-Host "winget $commandLine" -ForegroundColor Cyan
+  $p = Start-Process 'winget' -ArgumentList $commandLine -Wait -NoNewWindow -PassThru
+  Set-ExitCode $p.ExitCode
+  if ($p.ExitCode -ne 0 -and -not $global:SetupForMeRebootRequired) { throw "winget exited with code $($p.ExitCode)" }
+  return 'uninstalled'
+}
+
+function Invoke-UninstallCommand { param([string]$Command)
+  Set-ExitCode $null
+  Write-Host "cmd /c $Command" -ForegroundColor Cyan
+  $p = Start-Process 'cmd.exe' -ArgumentList @('/c', $Command) -Wait -NoNewWindow -PassThru
+  Set-ExitCode $p.ExitCode
+  # 1605: product is not installed; 1641 / 3010: success, reboot initiated / required
+  if ($p.ExitCode -notin 0, 1605, 1641, 3010) { throw "Uninstall command exited with code $($p.ExitCode)" }
+  return 'uninstalled'
+}
+
+# Runs a downloaded installer. Without args, $Type picks the silent switches.
+function Install-FromUrl { param([string]$Url, [string[]]$InstallerArgs, [string]$Sha256, [string]$Type)
+  Set-ExitCode $null
+  $fileName = [System.IO.Path]::GetFileName(([System.Uri]$Url).AbsolutePath)
+  if ([string]::IsNullOrWhiteSpace($fileName)) { $fileName = 'installer.exe' }
+  $dest = Join-Path $env:TEMP ("SetupForMe_" + [guid]::NewGuid().ToString() + '_' + $fileName)
+  Write-Host "Downloading $Url to $dest" -ForegroundColor DarkCyan
+  Invoke-WebRequest -Uri $Url -OutFile $dest
+  if ($Sha256) {
+    $actual = (Get-FileHash -Path $dest -Algorithm SHA256).Hash
+    if ($actual -ne $Sha256) {
+      Remove-Item -Path $dest -Force -ErrorAction SilentlyContinue
+      throw "Checksum mismatch for $Url (expected $Sha256, got $actual)"
+    }
+    Write-Host 'Checksum verified' -ForegroundColor DarkGreen
+  }
+  if ($Type -eq 'msix') {
+    Write-Host "Add-AppxPackage $dest" -ForegroundColor Cyan
+    Add-AppxPackage -Path $dest
+    return 'installed'
+  }
+  if ($Type -eq 'zip') {
+    $target = Join-Path $env:LOCALAPPDATA ('Programs\' + [System.IO.Path]::GetFileNameWithoutExtension($fileName))
+    Write-Host "Extracting to $target" -ForegroundColor Cyan
+    Expand-Archive -Path $dest -DestinationPath $target -Force
+    return 'installed'
+  }
+  $file = $dest
+  $argList = @()
+  if ($InstallerArgs) { $argList = $InstallerArgs }
+  if ($Type -eq 'msi') {
+    $file = 'msiexec.exe'
+    if ($InstallerArgs) { $argList = @('/i', $dest) + $InstallerArgs } else { $argList = @('/i', $dest, '/qn', '/norestart') }
+  } elseif (-not $InstallerArgs) {
+    switch ($Type) {
+      'nsis' { $argList = @('/S') }
+      'inno' { $argList = @('/VERYSILENT', '/SUPPRESSMSGBOXES', '/NORESTART', '/SP-') }
+      'wix-burn' { $argList = @('/quiet', '/norestart') }
+      default { Write-Host 'No args and no known installer type; the installer may show its wizard' -ForegroundColor DarkYellow }
+    }
+  }
+  $commandLine = ConvertTo-CommandLine $argList
+  Write-Host "$file $commandLine" -ForegroundColor Cyan
+  $psi = New-Object System.Diagnostics.ProcessStartInfo
+  $psi.FileName = $file
+  if ($commandLine) { $psi.Arguments = $commandLine }
+  $psi.UseShellExecute = $true
+  $p = [System.Diagnostics.Process]::Start($psi)
+  $p.WaitForExit()
+  Set-ExitCode $p.ExitCode
+  # 1641 / 3010: success, reboot initiated / required
+  if ($p.ExitCode -notin 0, 1641, 3010) { throw "Installer exited with code $($p.ExitCode)" }
+  return 'installed'
+}
+
+function Get-ElapsedMs { param([datetime]$Since)
+  return [long]((Get-Date) - $Since).TotalMilliseconds
+}
+
+# Runs one app's action and reports its outcome instead of throwing
+function Invoke-Step { param([scriptblock]$Action)
+  Set-ExitCode $null
+  $start = Get-Date
+  try {
+    $r = & $Action | Select-Object -Last 1
+    if ($global:SetupForMeRebootRequired) { $r = "$r (restart required)" }
+    [pscustomobject]@{ Result = [string]$r; ExitCode = $global:SetupForMeExitCode; Error = ''; DurationMs = (Get-ElapsedMs $start); RebootRequired = $global:SetupForMeRebootRequired }
+  } catch {
+    [pscustomobject]@{ Result = 'failed'; ExitCode = $global:SetupForMeExitCode; Error = $_.Exception.Message; DurationMs = (Get-ElapsedMs $start); RebootRequired = $false }
+  }
+}`
+
+// psStateHelpers track results for the summary and failures for dependencies
+const psStateHelpers = `# Per-app outcomes, printed as a summary at the end
+$summary = New-Object System.Collections.Generic.List[object]
+function Add-Result { param([int]$Id, [string]$App, [string]$Result, [string]$Detail = '', $ExitCode = $null, $DurationMs = $null)
+  $summary.Add([pscustomobject]@{ App = $App; Result = $Result; ExitCode = $ExitCode; Detail = $Detail })
+  if ($reportUrl) { Send-Result $Id $App $Result $Detail $ExitCode $DurationMs }
+  if ($StateFile) { $completedApps[$Id] = $true; Save-State }
+}
+
+# App IDs whose install failed, so dependents can be skipped
+$failedApps = @{}`
+
+// psReportHelpers post each app's result to the run created for this script.
+// Reporting problems are shown but never fail the installation.
+const psReportHelpers = `$reportHost = $env:COMPUTERNAME
+$reportOsBuild = try {
+  $cv = Get-ItemProperty 'HKLM:\SOFTWARE\Microsoft\Windows NT\CurrentVersion'
+  "$($cv.CurrentBuild).$($cv.UBR)"
+} catch { [System.Environment]::OSVersion.Version.ToString() }
+
+function Send-Result { param([int]$Id, [string]$App, [string]$Result, [string]$Detail, $ExitCode, $DurationMs)
+  $body = @{
+    app_id = $Id; app_name = $App; result = $Result
+    exit_code = $ExitCode; duration_ms = $DurationMs
+    hostname = $reportHost; os_build = $reportOsBuild
+  }
+  if ($Result -eq 'failed') { $body.error = $Detail }
+  try {
+    $json = $body | ConvertTo-Json -Compress
+    $null = Invoke-RestMethod -Method Post -Uri $reportUrl -Headers @{ 'X-Run-Token' = $reportToken } -ContentType 'application/json' -Body ([System.Text.Encoding]::UTF8.GetBytes($json))
+  } catch {
+    Write-Host "Could not report the result for ${App}: $($_.Exception.Message)" -ForegroundColor DarkGray
+  }
+}`
+
+// psParallelHelpers schedule apps as background jobs in parallel mode
+const psParallelHelpers = `function Test-PrerequisitesFailed { param([int[]]$DependsOn)
+  foreach ($d in $DependsOn) { if ($failedApps.ContainsKey($d)) { return $true } }
+  return $false
+}
+
+function Complete-Step { param([int]$Id, [string]$Name, $Outcome, [string]$Reboot = 'ignore')
+  if ($Outcome.Error) {
+    Write-Host ("Failed: $Name - " + $Outcome.Error) -ForegroundColor Red
+    $failedApps[$Id] = $true
+    Add-Result $Id $Name 'failed' $Outcome.Error $Outcome.ExitCode $Outcome.DurationMs
+  } else {
+    Write-Host "Finished: $Name" -ForegroundColor Green
+    Add-Result $Id $Name $Outcome.Result '' $Outcome.ExitCode $Outcome.DurationMs
+  }
+  if ($Reboot -ne 'ignore') { Request-Restart $Name $Reboot ([bool]$Outcome.RebootRequired) }
+}
+
+function Skip-Step { param([int]$Id, [string]$Name)
+  Write-Host "Skipping ${Name}: a prerequisite failed" -ForegroundColor DarkYellow
+  $failedApps[$Id] = $true
+  Add-Result $Id $Name 'skipped' 'prerequisite failed'
+}
+
+# Runs an app in this session; used for MSI installers, which cannot overlap
+function Invoke-SerialStep { param([int]$Id, [string]$Name, [int[]]$DependsOn, [scriptblock]$Action, [string]$Reboot = 'ignore')
+  if (Test-PrerequisitesFailed $DependsOn) { Skip-Step $Id $Name; return }
+  Write-Host "$stepVerb $Name..." -ForegroundColor Yellow
+  Complete-Step $Id $Name (Invoke-Step $Action) $Reboot
+}
+
+# Starts an app as a background job once fewer than $maxJobs are running
+function Start-StepJob { param([int]$Id, [string]$Name, [int[]]$DependsOn, [scriptblock]$Action, [string]$Reboot = 'ignore')
+  if (Test-PrerequisitesFailed $DependsOn) { Skip-Step $Id $Name; return }
+  while (@(Get-Job -State Running | Where-Object { $_.Name -like 'SetupForMe-*' }).Count -ge $maxJobs) {
+    Start-Sleep -Milliseconds 500
+  }
+  Write-Host "$stepVerb $Name in the background..." -ForegroundColor Yellow
+  $job = Start-Job -Name "SetupForMe-$Id" -InitializationScript $SetupForMeHelpers -ScriptBlock ([scriptblock]::Create("Invoke-Step { $Action }"))
+  return [pscustomobject]@{ Id = $Id; Name = $Name; Job = $job; Reboot = $Reboot }
+}
+
+# Waits for a stage's jobs and records each job's outcome and exit code
+function Wait-StepJobs { param([object[]]$Started)
+  foreach ($s in $Started) {
+    if (-not $s) { continue }
+    $outcome = Receive-Job -Job $s.Job -Wait -AutoRemoveJob | Select-Object -Last 1
+    if (-not $outcome) { $outcome = [pscustomobject]@{ Result = 'failed'; ExitCode = $null; Error = 'Job produced no result'; DurationMs = $null; RebootRequired = $false } }
+    Complete-Step $s.Id $s.Name $outcome $s.Reboot
+  }
+}`
+
+func generatePowerShellScript(apps []models.App, opts scriptOptions) string {
+	text := modeText[opts.Mode]
+	wrapped := needsLauncher(apps)
+
+	script := scriptbuilder.New(scriptbuilder.PowerShell)
+	script.Comment(fmt.Sprintf("SetupForMe - Generated %s Script", text.Title))
+	script.Comment("Generated on: " + time.Now().Format("2006-01-02 15:04:05"))
+	script.Blank()
+
+	body := scriptbuilder.New(scriptbuilder.PowerShell)
+	body.Raw("$ErrorActionPreference = 'Stop'", "")
+	body.Raw("$SetupForMeHelpers = {", indent(psInstallHelpers, "  "), "}", ". $SetupForMeHelpers", "")
+	body.Raw(psStateHelpers, "")
+	if wrapped {
+		body.Raw(psRunStateHelpers, "")
+	}
+	if opts.ReportURL != "" {
+		body.Line("$reportUrl = %s", opts.ReportURL)
+		body.Line("$reportToken = %s", opts.ReportToken)
+		body.Raw(psReportHelpers, "")
+	}
+	if opts.Parallel {
+		body.Line("$maxJobs = %s", opts.MaxJobs)
+		body.Line("$stepVerb = %s", text.Doing)
+		body.Raw(psParallelHelpers, "")
+	}
+	body.Line("Write-Host %s -ForegroundColor Green", "Starting application "+text.Noun+"...")
+	body.Blank()
+	if wrapped {
+		body.Raw("Import-State", "")
+	}
+
+	var phases map[int]string
+	if wrapped {
+		phases = stepPhases(apps, opts)
+	}
+	if opts.Parallel {
+		psParallelStages(body, apps, opts, phases)
+	} else {
+		psSerialSteps(body, apps, opts, phases)
+	}
+
+	if wrapped {
+		// The summary waits for the last phase and the last restart
+		body.Raw("Save-State", "if ($runState.Restart -or $Phase -eq 'admin') { return }", "")
+	}
+	if len(apps) == 0 {
+		body.Line("Write-Host %s -ForegroundColor Yellow", "No applications to "+opts.Mode+".")
+	} else {
+		body.Line("Write-Host %s -ForegroundColor Green", text.Done)
+		body.Raw("$summary | Format-Table -AutoSize | Out-String | Write-Host")
+	}
+
+	if !wrapped {
+		script.Raw(body.Lines()...)
+		return script.String()
+	}
+
+	// Wrap everything so the script can run itself again elevated or after a restart
+	script.Raw("$SetupForMeScript = {", "param([string]$Phase = 'all', [string]$StateFile = '')", "")
+	script.Raw(indent(body.String(), "  "), "}", "")
+	psLauncher(script, apps)
+
+	return script.String()
+}
+
+// psSerialSteps processes apps one after another, each in its own try/catch
+func psSerialSteps(b *scriptbuilder.Builder, apps []models.App, opts scriptOptions, phases map[int]string) {
+	text := modeText[opts.Mode]
+
+	for i, app := range apps {
+		appName := displayName(app)
+		deps := prerequisites(app, opts)
+
+		b.Comment(fmt.Sprintf("App %d: %s", i+1, appName))
+
+		step := scriptbuilder.New(scriptbuilder.PowerShell)
+		if len(deps) > 0 {
+			var checks []string
+			for _, dep := range deps {
+				checks = append(checks, string(step.Format("$failedApps.ContainsKey(%s)", dep)))
+			}
+			step.Line("if (%s) {", scriptbuilder.Code(strings.Join(checks, " -or ")))
+			step.Line("  Write-Host %s -ForegroundColor DarkYellow", "Skipping "+appName+": a prerequisite failed")
+			step.Line("  $failedApps[%s] = $true", app.ID)
+			step.Line("  Add-Result %s %s 'skipped' 'prerequisite failed'", app.ID, appName)
+			step.Raw("} else {")
+		}
+		step.Line("Write-Host %s -ForegroundColor Yellow", text.Doing+" "+appName+"...")
+		step.Raw("Set-ExitCode $null", "$stepStart = Get-Date", "try {")
+		psAppAction(step, app, opts)
+		step.Line("  Write-Host %s -ForegroundColor Green", "Finished: "+appName)
+		step.Raw("  if ($global:SetupForMeRebootRequired) { $result += ' (restart required)' }")
+		step.Line("  Add-Result %s %s $result '' $global:SetupForMeExitCode (Get-ElapsedMs $stepStart)", app.ID, appName)
+		step.Raw("} catch {")
+		step.Line("  Write-Host (%s + $_.Exception.Message) -ForegroundColor Red", "Failed: "+appName+" - ")
+		step.Line("  $failedApps[%s] = $true", app.ID)
+		step.Line("  Add-Result %s %s 'failed' $_.Exception.Message $global:SetupForMeExitCode (Get-ElapsedMs $stepStart)", app.ID, appName)
+		step.Raw("}")
+		if behavior := rebootBehavior(app); behavior != rebootIgnore {
+			step.Line("Request-Restart %s %s $global:SetupForMeRebootRequired", appName, behavior)
+		}
+		if len(deps) > 0 {
+			step.Raw("}")
+		}
+		psStepGuard(b, app, phases, step.Lines())
+		b.Blank()
+	}
+}
+
+// psParallelStages processes apps stage by stage; every app in a stage only
+// depends on apps in earlier stages. Within a stage, MSI installers run one at
+// a time in this session because they hold the Windows Installer mutex, then
+// everything else runs as background jobs.
+func psParallelStages(b *scriptbuilder.Builder, apps []models.App, opts scriptOptions, phases map[int]string) {
+	stages := dependencyStages(apps, opts.Mode == modeUninstall)
+
+	unknown := 0
+	for _, app := range apps {
+		if _, known := opts.WindowsInstaller[strings.ToLower(app.WingetID)]; app.WingetID != "" && !known {
+			unknown++
+		}
+	}
+	if unknown > 0 {
+		b.Comment(fmt.Sprintf("The installer type of %d winget app(s) is not in the offline catalog, so they run as jobs. "+
+			"An MSI installer among them can fail with 1618 while another MSI install is running.", unknown))
+		b.Blank()
+	}
+
+	for i, stage := range stages {
+		stageName := fmt.Sprintf("Stage %d of %d", i+1, len(stages))
+		b.Comment(stageName)
+		b.Line("Write-Host %s -ForegroundColor Green", stageName)
+
+		var background []models.App
+		for _, app := range stage {
+			if holdsInstallerMutex(app, opts) {
+				psStepGuard(b, app, phases, []string{string(psStepCall(b, "Invoke-SerialStep", app, opts))})
+			} else {
+				background = append(background, app)
+			}
+		}
+
+		if len(background) > 0 {
+			b.Raw("$jobs = @()")
+			for _, app := range background {
+				psStepGuard(b, app, phases, []string{"$jobs += " + string(psStepCall(b, "Start-StepJob", app, opts))})
+			}
+			b.Raw("Wait-StepJobs $jobs")
+		}
+		b.Blank()
+	}
+}
+
+// psStepCall renders a call to one of the step schedulers for an app
+func psStepCall(b *scriptbuilder.Builder, fn string, app models.App, opts scriptOptions) scriptbuilder.Code {
+	action := scriptbuilder.New(scriptbuilder.PowerShell)
+	psAppAction(action, app, opts)
+
+	var statements []string
+	for _, line := range action.Lines() {
+		statements = append(statements, strings.TrimSpace(line))
+	}
+
+	call := b.Format("%s -Id %s -Name %s -DependsOn %s -Action { %s; $result }",
+		scriptbuilder.Code(fn), app.ID, displayName(app), prerequisites(app, opts),
+		scriptbuilder.Code(strings.Join(statements, "; ")))
+	if behavior := rebootBehavior(app); behavior != rebootIgnore {
+		call += b.Format(" -Reboot %s", behavior)
+	}
+	return call
+}
+
+// holdsInstallerMutex reports whether the app's action runs Windows
+// Installer, so it must not overlap with another MSI operation. A winget app
+// only counts when the catalog lists an MSI, WiX or Burn installer for it;
+// winget apps of unknown installer type run as jobs.
+func holdsInstallerMutex(app models.App, opts scriptOptions) bool {
+	if app.WingetID != "" {
+		return opts.WindowsInstaller[strings.ToLower(app.WingetID)]
+	}
+	if opts.Mode == modeUninstall {
+		return strings.Contains(strings.ToLower(app.UninstallCommand), "msiexec")
+	}
+	return installerType(app) == utils.InstallerMSI
+}
+
+// installerType returns the app's installer type, inferring it from the URL
+// for apps saved before the type was recorded
+func installerType(app models.App) string {
+	if app.InstallerType != "" {
+		return app.InstallerType
+	}
+	return utils.InstallerTypeFromURL(app.DownloadURL)
+}
+
+// psAppAction appends the lines that perform the mode's action for one app
+// and leave a short description of the outcome in $result. Installer args are
+// passed as an array, one element per argument.
+func psAppAction(b *scriptbuilder.Builder, app models.App, opts scriptOptions) {
+	args := scriptbuilder.SplitArgs(app.Args)
+	if args == nil {
+		args = []string{}
+	}
+
+	switch {
+	case app.WingetID != "" && opts.Mode == modeUninstall:
+		b.Line("  $result = Uninstall-WingetApp %s %s", app.WingetID, app.Scope)
+	case app.WingetID != "" && opts.Mode == modeUpgrade:
+		b.Line("  $result = Update-WingetApp %s %s %s %s", app.WingetID, args, app.Version, app.Scope)
+	case app.WingetID != "":
+		b.Line("  $result = Install-WingetApp %s %s %s %s %s", app.WingetID, args, app.Version, installPolicy(app, opts), app.Scope)
+	case app.DownloadURL != "" && opts.Mode == modeUninstall:
+		if app.UninstallCommand == "" {
+			b.Raw("  Write-Host 'No uninstall command provided.' -ForegroundColor DarkYellow")
+			b.Raw("  $result = 'skipped (no uninstall command)'")
+			return
+		}
+		// The uninstall command is a cmd.exe command line by design
+		b.Line("  $result = Invoke-UninstallCommand %s", app.UninstallCommand)
+	case app.DownloadURL != "":
+		// Upgrading a URL-based app means running its (latest) installer again
+		b.Line("  $result = Install-FromUrl %s %s %s %s", app.DownloadURL, args, app.SHA256, installerType(app))
+	default:
+		b.Raw("  Write-Host 'No installer info provided.' -ForegroundColor DarkYellow")
+		b.Raw("  $result = 'no installer'")
+	}
+}
+
+// installPolicy picks the app's own policy, falling back to the request's
+func installPolicy(app models.App, opts scriptOptions) string {
+	if app.InstallPolicy != "" {
+		return app.InstallPolicy
+	}
+	return opts.Policy
+}
+
+// prerequisites returns the app IDs that must have succeeded before this app
+// is processed. Uninstalls run dependents first, so nothing is skipped there.
+func prerequisites(app models.App, opts scriptOptions) []int {
+	if opts.Mode == modeUninstall {
+		return nil
+	}
+	return app.DependsOn
+}
+
+// indent prefixes every non-empty line of text
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
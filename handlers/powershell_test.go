@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"setupforme/models"
+)
+
+// Only winget apps the catalog lists with a Windows Installer package hold the
+// installer mutex; the rest run as jobs like other apps
+func TestParallelScriptSerializesWindowsInstallerApps(t *testing.T) {
+	apps := []models.App{
+		{ID: 1, Name: "Git", WingetID: "Git.Git"},
+		{ID: 2, Name: "7-Zip", WingetID: "7zip.7zip"},
+		{ID: 3, Name: "Node.js", WingetID: "OpenJS.NodeJS"},
+		{ID: 4, Name: "Tool", DownloadURL: "https://example.com/tool.exe", InstallerType: "nsis"},
+		{ID: 5, Name: "Agent", DownloadURL: "https://example.com/agent.msi"},
+	}
+
+	opts, err := parseScriptOptions(map[string][]string{"target": {targetPowerShell}, "parallel": {"true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.WindowsInstaller = map[string]bool{"git.git": false, "7zip.7zip": true}
+	script := scriptGenerators[targetPowerShell](apps, opts)
+
+	serial := map[string]bool{}
+	jobs := map[string]bool{}
+	for _, line := range strings.Split(script, "\n") {
+		for _, app := range apps {
+			if !strings.Contains(line, "-Name '"+app.Name+"'") {
+				continue
+			}
+			if strings.Contains(line, "Invoke-SerialStep -Id") {
+				serial[app.Name] = true
+			}
+			if strings.Contains(line, "Start-StepJob -Id") {
+				jobs[app.Name] = true
+			}
+		}
+	}
+
+	for _, name := range []string{"7-Zip", "Agent"} {
+		if !serial[name] || jobs[name] {
+			t.Errorf("%s should run as a serial step", name)
+		}
+	}
+	for _, name := range []string{"Git", "Node.js", "Tool"} {
+		if !jobs[name] || serial[name] {
+			t.Errorf("%s should run as a background job", name)
+		}
+	}
+
+	if !strings.Contains(script, "The installer type of 1 winget app(s) is not in the offline catalog") {
+		t.Error("script does not note the winget app missing from the catalog")
+	}
+}
+
+// The elevated pass runs before the user phase, so a user app that an admin
+// app depends on has to be installable from it too
+func TestElevatedScriptRunsPrerequisitesOfAdminApps(t *testing.T) {
+	apps := []models.App{
+		{ID: 1, Name: "Runtime", WingetID: "Example.Runtime"},
+		{ID: 2, Name: "Tool", WingetID: "Example.Tool", Scope: scopeMachine, DependsOn: []int{1}},
+		{ID: 3, Name: "Editor", WingetID: "Example.Editor"},
+	}
+
+	for _, parallel := range []string{"false", "true"} {
+		opts, err := parseScriptOptions(map[string][]string{"target": {targetPowerShell}, "parallel": {parallel}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		script := scriptGenerators[targetPowerShell](apps, opts)
+
+		for _, guard := range []string{"Test-Step 1 'any'", "Test-Step 2 'admin'", "Test-Step 3 'user'"} {
+			if !strings.Contains(script, guard) {
+				t.Errorf("parallel=%s: script does not contain %q", parallel, guard)
+			}
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"setupforme/models"
+
+	"github.com/lib/pq"
+)
+
+// defaultProfileName names the profile created for a user's first apps
+const defaultProfileName = "Default"
+
+// errProfileNotFound is returned for a profile that does not exist or belongs
+// to another user
+var errProfileNotFound = errors.New("profile not found")
+
+// defaultProfile returns the ID of the user's default profile, creating it
+// for a user who has none yet
+func (h *AppHandler) defaultProfile(userID int) (int, error) {
+	var profileID int
+	err := h.db.QueryRow("SELECT id FROM profiles WHERE user_id = $1 AND is_default", userID).Scan(&profileID)
+	if err != sql.ErrNoRows {
+		return profileID, err
+	}
+
+	err = h.db.QueryRow(`
+		INSERT INTO profiles (user_id, name, is_default)
+		VALUES ($1, $2, TRUE)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, userID, defaultProfileName).Scan(&profileID)
+	if err == sql.ErrNoRows {
+		// Created by a concurrent request
+		err = h.db.QueryRow("SELECT id FROM profiles WHERE user_id = $1 AND is_default", userID).Scan(&profileID)
+	}
+	return profileID, err
+}
+
+// lookupProfile returns profileID when the user owns that profile, or the
+// user's default profile when profileID is 0
+func (h *AppHandler) lookupProfile(userID, profileID int) (int, error) {
+	if profileID == 0 {
+		return h.defaultProfile(userID)
+	}
+
+	var ownerID int
+	err := h.db.QueryRow("SELECT user_id FROM profiles WHERE id = $1", profileID).Scan(&ownerID)
+	if err == sql.ErrNoRows || (err == nil && ownerID != userID) {
+		return 0, errProfileNotFound
+	}
+	return profileID, err
+}
+
+// profileParam reads the optional profile_id query parameter (0 when absent)
+func profileParam(r *http.Request) (int, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("profile_id"))
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 1 {
+		return 0, errors.New("profile_id must be a positive integer")
+	}
+	return id, nil
+}
+
+// requestedProfile resolves the profile_id query parameter of a request to
+// one of the user's profiles, writing the error response when it cannot
+func (h *AppHandler) requestedProfile(w http.ResponseWriter, r *http.Request, userID int) (int, bool) {
+	requested, err := profileParam(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+
+	profileID, err := h.lookupProfile(userID, requested)
+	if err != nil {
+		if errors.Is(err, errProfileNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return 0, false
+	}
+	return profileID, true
+}
+
+// bodyProfile resolves a profile_id given in a request body to one of the
+// user's profiles, writing the error response when it cannot
+func (h *AppHandler) bodyProfile(w http.ResponseWriter, userID, requested int) (int, bool) {
+	profileID, err := h.lookupProfile(userID, requested)
+	if err != nil {
+		if errors.Is(err, errProfileNotFound) {
+			writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("profile_id references unknown profile %d", requested))
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return 0, false
+	}
+	return profileID, true
+}
+
+// ownedProfile checks that the profile in the request path exists and belongs
+// to the user, writing the error response when it does not
+func (h *AppHandler) ownedProfile(w http.ResponseWriter, r *http.Request, userID int) (int, bool) {
+	profileID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid profile ID")
+		return 0, false
+	}
+
+	var ownerID int
+	err = h.db.QueryRow("SELECT user_id FROM profiles WHERE id = $1", profileID).Scan(&ownerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return 0, false
+	}
+
+	if ownerID != userID {
+		writeErrorResponse(w, http.StatusForbidden, "You can only access your own profiles")
+		return 0, false
+	}
+	return profileID, true
+}
+
+// fetchProfiles loads the user's profiles with their app counts, the default
+// profile first. A profileID other than 0 loads only that profile.
+func (h *AppHandler) fetchProfiles(userID, profileID int) ([]models.Profile, error) {
+	rows, err := h.db.Query(`
+		SELECT p.id, p.user_id, p.name, p.description, p.is_default, p.visibility, COALESCE(p.slug, ''),
+			COALESCE(u.slug, ''), p.forked_at, p.created_at, COUNT(a.id)
+		FROM profiles p
+		LEFT JOIN profiles u ON u.id = p.upstream_profile_id
+		LEFT JOIN apps a ON a.profile_id = p.id
+		WHERE p.user_id = $1 AND ($2 = 0 OR p.id = $2)
+		GROUP BY p.id, u.id
+		ORDER BY p.is_default DESC, p.name
+	`, userID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.Profile{}
+	for rows.Next() {
+		var p models.Profile
+		var forkedAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.Description, &p.IsDefault, &p.Visibility, &p.Slug,
+			&p.UpstreamSlug, &forkedAt, &p.CreatedAt, &p.AppCount); err != nil {
+			return nil, err
+		}
+		if forkedAt.Valid {
+			p.ForkedAt = &forkedAt.Time
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetProfiles lists the user's profiles
+func (h *AppHandler) GetProfiles(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	// Every user has a default profile to put apps in
+	if _, err := h.defaultProfile(userID); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	profiles, err := h.fetchProfiles(userID, 0)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profiles")
+		return
+	}
+
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// GetProfile returns one profile with its apps
+func (h *AppHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.ownedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	profiles, err := h.fetchProfiles(userID, profileID)
+	if err != nil || len(profiles) == 0 {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	profile := profiles[0]
+
+	profile.Apps, err = h.fetchApps(userID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *AppHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	var req models.CreateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if msg := validateProfileName(req.Name); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// The first profile a user creates becomes the default
+	profile := models.Profile{UserID: userID, Name: req.Name, Description: req.Description}
+	err := h.db.QueryRow(`
+		INSERT INTO profiles (user_id, name, description, is_default)
+		VALUES ($1, $2, $3, NOT EXISTS (SELECT 1 FROM profiles WHERE user_id = $1 AND is_default))
+		RETURNING id, is_default, created_at
+	`, userID, req.Name, req.Description).Scan(&profile.ID, &profile.IsDefault, &profile.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			writeErrorResponse(w, http.StatusConflict, "A profile with this name already exists")
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to create profile")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateProfile renames a profile, makes it the default or changes who can
+// see it. The default profile changes by making another profile the default.
+// Sharing a profile for the first time gives it its slug.
+func (h *AppHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.ownedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if msg := validateProfileName(req.Name); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	// A slug is only stored when the profile has none yet
+	var slug sql.NullString
+	switch req.Visibility {
+	case "", visibilityPrivate:
+	case visibilityUnlisted, visibilityPublic:
+		s, err := newProfileSlug(req.Name)
+		if err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate slug")
+			return
+		}
+		slug = sql.NullString{String: s, Valid: true}
+	default:
+		writeErrorResponse(w, http.StatusBadRequest, "visibility must be private, unlisted or public")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	if req.IsDefault {
+		if _, err := tx.Exec("UPDATE profiles SET is_default = FALSE WHERE user_id = $1 AND id <> $2", userID, profileID); err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE profiles SET name = $1, description = $2, is_default = is_default OR $3,
+			visibility = COALESCE(NULLIF($5, ''), visibility), slug = COALESCE(slug, $6)
+		WHERE id = $4
+	`, req.Name, req.Description, req.IsDefault, profileID, req.Visibility, slug)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			writeErrorResponse(w, http.StatusConflict, "A profile with this name already exists")
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update profile")
+		return
+	}
+
+	profiles, err := h.fetchProfiles(userID, profileID)
+	if err != nil || len(profiles) == 0 {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	json.NewEncoder(w).Encode(profiles[0])
+}
+
+// DeleteProfile deletes a profile together with its apps. The default profile
+// cannot be deleted.
+func (h *AppHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.ownedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM profiles WHERE id = $1 AND NOT is_default", profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to delete profile")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "The default profile cannot be deleted; make another profile the default first")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ProfileScript generates the install script for one profile's apps. It takes
+// the same query parameters as GET /api/apps/script.
+func (h *AppHandler) ProfileScript(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	profileID, ok := h.ownedProfile(w, r, userID)
+	if !ok {
+		return
+	}
+
+	opts, err := parseScriptOptions(r.URL.Query())
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.ProfileID = profileID
+
+	h.writeScript(w, r, userID, opts)
+}
+
+func validateProfileName(name string) string {
+	if name == "" {
+		return "Profile name is required"
+	}
+	if len(name) > 255 {
+		return "Profile name must be at most 255 characters"
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"setupforme/utils"
+)
+
+// How a stored winget_id was chosen
+const (
+	resolutionAuto      = "auto"      // resolved from the app name without asking
+	resolutionConfirmed = "confirmed" // given or picked by the user
+)
+
+// Bounds for resolving a winget_id from an app name. The best candidate is
+// only taken without asking when it is confident enough and clearly ahead of
+// the runner-up; otherwise the client gets the candidates to choose from.
+const (
+	resolveCandidates     = 5
+	minAutoConfidence     = 0.75
+	minConfidenceMargin   = 0.15
+	sourceRankWeight      = 0.3 // share of the confidence taken from the source's own ranking
+	partialWordSimilarity = 0.6
+)
+
+type ResolutionCandidate struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Publisher  string  `json:"publisher"`
+	Version    string  `json:"version,omitempty"`
+	Confidence float64 `json:"confidence"` // 0 to 1
+}
+
+// AmbiguousResolutionResponse is returned with 300 Multiple Choices when an
+// app name matches several packages about equally well
+type AmbiguousResolutionResponse struct {
+	Error      string                `json:"error"`
+	Message    string                `json:"message"`
+	Candidates []ResolutionCandidate `json:"candidates"`
+}
+
+// rankCandidates scores packages found for an app name, best first. The
+// confidence mixes how closely the package name or ID matches the app name
+// with the position the source ranked the package at.
+func rankCandidates(name string, packages []utils.WingetPackage) []ResolutionCandidate {
+	candidates := make([]ResolutionCandidate, 0, len(packages))
+	for i, pkg := range packages {
+		confidence := (1-sourceRankWeight)*nameSimilarity(name, pkg) + sourceRankWeight/float64(i+1)
+		candidate := ResolutionCandidate{
+			ID:         pkg.Id,
+			Name:       pkg.Latest.Name,
+			Publisher:  pkg.Latest.Publisher,
+			Confidence: float64(int(confidence*100+0.5)) / 100,
+		}
+		if len(pkg.Versions) > 0 {
+			candidate.Version = pkg.Versions[0]
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	return candidates
+}
+
+// isConfident reports whether the best of ranked candidates can be taken
+// without asking the user
+func isConfident(candidates []ResolutionCandidate) bool {
+	if len(candidates) == 0 || candidates[0].Confidence < minAutoConfidence {
+		return false
+	}
+	return len(candidates) == 1 || candidates[0].Confidence-candidates[1].Confidence >= minConfidenceMargin
+}
+
+// nameSimilarity scores from 0 to 1 how well a package matches an app name.
+// An exact name, ID or last ID segment scores 1; a name made of the app name
+// plus more words scores at least partialWordSimilarity; anything else is
+// scored by trigram similarity.
+func nameSimilarity(name string, pkg utils.WingetPackage) float64 {
+	query := normalizeName(name)
+	if query == "" {
+		return 0
+	}
+
+	id := normalizeName(pkg.Id)
+	last := pkg.Id
+	if i := strings.LastIndex(last, "."); i >= 0 {
+		last = last[i+1:]
+	}
+	fields := []string{normalizeName(pkg.Latest.Name), normalizeName(last), id}
+
+	best := 0.0
+	for _, field := range fields {
+		if field == query || strings.ReplaceAll(field, " ", "") == strings.ReplaceAll(query, " ", "") {
+			return 1
+		}
+		score := trigramSimilarity(query, field)
+		if strings.Contains(" "+field+" ", " "+query+" ") {
+			score = max(score, partialWordSimilarity)
+		}
+		best = max(best, score)
+	}
+	return best
+}
+
+// normalizeName lowercases s and reduces it to words of letters and digits
+func normalizeName(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// trigramSimilarity compares the trigrams of two normalized strings like
+// Postgres pg_trgm does: shared trigrams over all distinct trigrams
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the trigrams of each word, padded like pg_trgm
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+const (
+	// runReportWindow is how long after generation a script may report results
+	runReportWindow = 7 * 24 * time.Hour
+	// maxReportSize bounds a single result posted by a script
+	maxReportSize = 64 << 10
+)
+
+// startRun records a new run for a reporting script and returns its ID and
+// the token the script authenticates its reports with
+func (h *AppHandler) startRun(userID int, opts scriptOptions) (int, string, error) {
+	token, tokenHash, err := utils.GenerateToken()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var runID int
+	err = h.db.QueryRow(`
+		INSERT INTO install_runs (user_id, token_hash, params)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, userID, tokenHash, opts.encode().Encode()).Scan(&runID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return runID, token, nil
+}
+
+// ReportResult stores one app's outcome posted by a running script. The script
+// authenticates with the run token in the X-Run-Token header instead of a JWT.
+func (h *AppHandler) ReportResult(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+
+	token := r.Header.Get("X-Run-Token")
+	if token == "" {
+		writeErrorResponse(w, http.StatusUnauthorized, "Run token required")
+		return
+	}
+
+	var req models.ReportResultRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportSize)).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.AppName = strings.TrimSpace(req.AppName)
+	req.Result = strings.TrimSpace(req.Result)
+	if req.AppName == "" || req.Result == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "app_name and result are required")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// The token must match this run, which must still be accepting reports
+	var userID int
+	err = tx.QueryRow(`
+		UPDATE install_runs
+		SET hostname = COALESCE(NULLIF($3, ''), hostname),
+			os_build = COALESCE(NULLIF($4, ''), os_build),
+			last_reported_at = NOW()
+		WHERE id = $1 AND token_hash = $2 AND created_at > $5
+		RETURNING user_id
+	`, runID, utils.HashToken(token), truncate(req.Hostname, 255), truncate(req.OSBuild, 64),
+		time.Now().Add(-runReportWindow)).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusUnauthorized, "Invalid or expired run token")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	// app_id is only kept when it is one of the run owner's apps
+	var result models.InstallResult
+	var appID sql.NullInt64
+	err = tx.QueryRow(`
+		INSERT INTO install_results (run_id, app_id, app_name, result, exit_code, duration_ms, error)
+		VALUES ($1, (SELECT id FROM apps WHERE id = $2 AND user_id = $3), $4, $5, $6, $7, $8)
+		RETURNING id, app_id, reported_at
+	`, runID, req.AppID, userID, truncate(req.AppName, 255), truncate(req.Result, 64),
+		req.ExitCode, req.DurationMs, truncate(req.Error, 4000)).Scan(&result.ID, &appID, &result.ReportedAt)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to save result")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to save result")
+		return
+	}
+
+	if appID.Valid {
+		id := int(appID.Int64)
+		result.AppID = &id
+	}
+	result.AppName = truncate(req.AppName, 255)
+	result.Result = truncate(req.Result, 64)
+	result.ExitCode = req.ExitCode
+	result.DurationMs = req.DurationMs
+	result.Error = truncate(req.Error, 4000)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetRuns lists the user's runs, newest first, with per-app failure rates
+func (h *AppHandler) GetRuns(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	runs, err := h.fetchRuns(userID, 0)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch runs")
+		return
+	}
+
+	stats, err := h.fetchAppRunStats(userID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch run statistics")
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.RunHistory{Runs: runs, Apps: stats})
+}
+
+// GetRun returns one run with every result reported for it
+func (h *AppHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	runID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+
+	// Check if run exists and belongs to user
+	var existingUserID int
+	err = h.db.QueryRow("SELECT user_id FROM install_runs WHERE id = $1", runID).Scan(&existingUserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Run not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	if existingUserID != userID {
+		writeErrorResponse(w, http.StatusForbidden, "You can only view your own runs")
+		return
+	}
+
+	runs, err := h.fetchRuns(userID, runID)
+	if err != nil || len(runs) == 0 {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch run")
+		return
+	}
+	run := runs[0]
+
+	rows, err := h.db.Query(`
+		SELECT id, app_id, app_name, result, exit_code, duration_ms, error, reported_at
+		FROM install_results WHERE run_id = $1
+		ORDER BY reported_at, id
+	`, runID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch results")
+		return
+	}
+	defer rows.Close()
+
+	run.Results = []models.InstallResult{}
+	for rows.Next() {
+		var res models.InstallResult
+		var appID, exitCode, durationMs sql.NullInt64
+		var errText sql.NullString
+
+		err := rows.Scan(&res.ID, &appID, &res.AppName, &res.Result, &exitCode, &durationMs, &errText, &res.ReportedAt)
+		if err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to scan result")
+			return
+		}
+
+		if appID.Valid {
+			id := int(appID.Int64)
+			res.AppID = &id
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			res.ExitCode = &code
+		}
+		if durationMs.Valid {
+			res.DurationMs = &durationMs.Int64
+		}
+		res.Error = errText.String
+
+		run.Results = append(run.Results, res)
+	}
+
+	json.NewEncoder(w).Encode(run)
+}
+
+// fetchRuns returns the user's runs with result counts, newest first.
+// A non-zero runID restricts the list to that run.
+func (h *AppHandler) fetchRuns(userID, runID int) ([]models.InstallRun, error) {
+	rows, err := h.db.Query(`
+		SELECT r.id, r.params, r.hostname, r.os_build, r.created_at, r.last_reported_at,
+			COUNT(res.id) FILTER (WHERE res.result = 'failed'),
+			COUNT(res.id) FILTER (WHERE res.result LIKE 'skipped%'),
+			COUNT(res.id)
+		FROM install_runs r
+		LEFT JOIN install_results res ON res.run_id = r.id
+		WHERE r.user_id = $1 AND ($2 = 0 OR r.id = $2)
+		GROUP BY r.id
+		ORDER BY r.created_at DESC, r.id DESC
+	`, userID, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.InstallRun{}
+	for rows.Next() {
+		var run models.InstallRun
+		var params string
+		var hostname, osBuild sql.NullString
+		var lastReportedAt sql.NullTime
+		var total int
+
+		err := rows.Scan(&run.ID, &params, &hostname, &osBuild, &run.CreatedAt, &lastReportedAt,
+			&run.Failed, &run.Skipped, &total)
+		if err != nil {
+			return nil, err
+		}
+
+		values, _ := url.ParseQuery(params)
+		run.Options = flattenValues(values)
+		run.Hostname = hostname.String
+		run.OSBuild = osBuild.String
+		if lastReportedAt.Valid {
+			run.LastReportedAt = &lastReportedAt.Time
+		}
+		run.Succeeded = total - run.Failed - run.Skipped
+
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// fetchAppRunStats computes, per app, how many reported runs attempted it and
+// how many of those failed. Skipped apps are not counted as attempts.
+func (h *AppHandler) fetchAppRunStats(userID int) ([]models.AppRunStats, error) {
+	rows, err := h.db.Query(`
+		SELECT res.app_id,
+			(ARRAY_AGG(res.app_name ORDER BY res.reported_at DESC))[1],
+			COUNT(DISTINCT res.run_id),
+			COUNT(DISTINCT res.run_id) FILTER (WHERE res.result = 'failed')
+		FROM install_results res
+		JOIN install_runs r ON r.id = res.run_id
+		WHERE r.user_id = $1 AND res.result NOT LIKE 'skipped%'
+		GROUP BY res.app_id, CASE WHEN res.app_id IS NULL THEN res.app_name END
+		ORDER BY 4 DESC, 2
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.AppRunStats{}
+	for rows.Next() {
+		var s models.AppRunStats
+		var appID sql.NullInt64
+
+		if err := rows.Scan(&appID, &s.AppName, &s.Runs, &s.Failures); err != nil {
+			return nil, err
+		}
+
+		if appID.Valid {
+			id := int(appID.Int64)
+			s.AppID = &id
+		}
+		if s.Runs > 0 {
+			s.FailureRate = float64(s.Failures) / float64(s.Runs)
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"setupforme/models"
+	"setupforme/scriptbuilder"
+)
+
+// Supported script targets
+const (
+	targetPowerShell = "powershell"
+	targetBashDebian = "bash-debian"
+	targetBashFedora = "bash-fedora"
+	targetBrew       = "brew"
+)
+
+// scriptGenerators maps a target name to the function that renders its script
+var scriptGenerators = map[string]func(apps []models.App, opts scriptOptions) string{
+	targetPowerShell: generatePowerShellScript,
+	targetBashDebian: generateBashScript,
+	targetBashFedora: generateBashScript,
+	targetBrew:       generateBrewScript,
+}
+
+// scriptOptions controls how a script is rendered. They are parsed from query
+// parameters so that stored links can replay the same request later.
+type scriptOptions struct {
+	Target string
+	Mode   string
+	Policy string // default for apps without their own install_policy
+
+	// ProfileID selects the profile whose apps are rendered (the default profile when 0)
+	ProfileID int
+
+	// Parallel runs independent PowerShell steps as background jobs, at most MaxJobs at a time
+	Parallel bool
+	MaxJobs  int
+
+	// WindowsInstaller records, for the winget apps whose installer types the
+	// catalog knows, whether their installer runs Windows Installer. It is
+	// keyed by lowercased winget_id and filled in per render.
+	WindowsInstaller map[string]bool
+
+	// Report makes a PowerShell script post each app's result to the backend.
+	// ReportURL and ReportToken are filled in per render and never stored.
+	Report      bool
+	ReportURL   string
+	ReportToken string
+}
+
+// Script modes
+const (
+	modeInstall   = "install"
+	modeUninstall = "uninstall"
+	modeUpgrade   = "upgrade"
+)
+
+// modeText holds the wording generated scripts use for each mode
+var modeText = map[string]struct{ Title, Doing, Noun, Done string }{
+	modeInstall:   {"Installation", "Installing", "installation", "Installation complete!"},
+	modeUninstall: {"Uninstall", "Uninstalling", "removal", "Uninstall complete!"},
+	modeUpgrade:   {"Upgrade", "Upgrading", "upgrade", "Upgrade complete!"},
+}
+
+// Install policies for packages that may already be present
+const (
+	policySkip    = "skip"
+	policyUpgrade = "upgrade"
+	policyForce   = "force"
+)
+
+// Install scopes for winget packages
+const (
+	scopeUser    = "user"
+	scopeMachine = "machine"
+)
+
+// What the script does when an installer reports that a restart is required
+const (
+	rebootIgnore    = "ignore"
+	rebootDefer     = "defer"
+	rebootImmediate = "immediate"
+)
+
+// Bounds for the number of concurrent background jobs in parallel mode
+const (
+	defaultMaxJobs = 4
+	maxMaxJobs     = 16
+)
+
+func isValidPolicy(p string) bool {
+	return p == policySkip || p == policyUpgrade || p == policyForce
+}
+
+// parseScriptOptions reads the script query parameters:
+//   - target: powershell (default), bash-debian, bash-fedora or brew
+//   - mode: install (default), uninstall or upgrade
+//   - policy: skip (default), upgrade or force for already-installed winget apps
+//   - parallel: true to run independent PowerShell steps concurrently
+//   - max_jobs: concurrent jobs in parallel mode, 1 to 16 (default 4)
+//   - report: true to have a PowerShell script report per-app results as a run
+//   - profile_id: the profile to render (default: the user's default profile)
+func parseScriptOptions(q url.Values) (scriptOptions, error) {
+	opts := scriptOptions{
+		Target:  strings.TrimSpace(q.Get("target")),
+		Mode:    strings.TrimSpace(q.Get("mode")),
+		Policy:  strings.TrimSpace(q.Get("policy")),
+		MaxJobs: defaultMaxJobs,
+	}
+
+	if opts.Target == "" {
+		opts.Target = targetPowerShell
+	}
+	if _, ok := scriptGenerators[opts.Target]; !ok {
+		return opts, errors.New("unknown target (expected powershell, bash-debian, bash-fedora or brew)")
+	}
+
+	if opts.Mode == "" {
+		opts.Mode = modeInstall
+	}
+	if _, ok := modeText[opts.Mode]; !ok {
+		return opts, errors.New("unknown mode (expected install, uninstall or upgrade)")
+	}
+
+	if opts.Policy == "" {
+		opts.Policy = policySkip
+	}
+	if !isValidPolicy(opts.Policy) {
+		return opts, errors.New("unknown policy (expected skip, upgrade or force)")
+	}
+
+	switch strings.TrimSpace(q.Get("parallel")) {
+	case "", "false", "0":
+	case "true", "1":
+		opts.Parallel = true
+	default:
+		return opts, errors.New("parallel must be true or false")
+	}
+
+	if v := strings.TrimSpace(q.Get("max_jobs")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMaxJobs {
+			return opts, errors.New("max_jobs must be between 1 and 16")
+		}
+		opts.MaxJobs = n
+	}
+
+	switch strings.TrimSpace(q.Get("report")) {
+	case "", "false", "0":
+	case "true", "1":
+		if opts.Target != targetPowerShell {
+			return opts, errors.New("report is only supported for the powershell target")
+		}
+		opts.Report = true
+	default:
+		return opts, errors.New("report must be true or false")
+	}
+
+	if v := strings.TrimSpace(q.Get("profile_id")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return opts, errors.New("profile_id must be a positive integer")
+		}
+		opts.ProfileID = n
+	}
+
+	return opts, nil
+}
+
+// encode turns the options back into query parameters
+func (o scriptOptions) encode() url.Values {
+	q := url.Values{}
+	q.Set("target", o.Target)
+	q.Set("mode", o.Mode)
+	q.Set("policy", o.Policy)
+	if o.Parallel {
+		q.Set("parallel", "true")
+		q.Set("max_jobs", strconv.Itoa(o.MaxJobs))
+	}
+	if o.Report {
+		q.Set("report", "true")
+	}
+	if o.ProfileID != 0 {
+		q.Set("profile_id", strconv.Itoa(o.ProfileID))
+	}
+	return q
+}
+
+func displayName(app models.App) string {
+	if app.Name == "" {
+		return "Unknown App"
+	}
+	return app.Name
+}
+
+// generateBashScript renders a bash script for Debian (apt) or Fedora (dnf)
+// based systems. Apps without a native package fall back to Flatpak.
+func generateBashScript(apps []models.App, opts scriptOptions) string {
+	target := opts.Target
+	text := modeText[opts.Mode]
+
+	manager, update := "apt", "sudo apt-get update"
+	commands := map[string]string{
+		modeInstall:   "sudo apt-get install -y",
+		modeUninstall: "sudo apt-get remove -y",
+		modeUpgrade:   "sudo apt-get install --only-upgrade -y",
+	}
+	if target == targetBashFedora {
+		manager, update = "dnf", "sudo dnf makecache"
+		commands = map[string]string{
+			modeInstall:   "sudo dnf install -y",
+			modeUninstall: "sudo dnf remove -y",
+			modeUpgrade:   "sudo dnf upgrade -y",
+		}
+	}
+	flatpakCommands := map[string]string{
+		modeInstall:   "flatpak install -y --noninteractive flathub",
+		modeUninstall: "flatpak uninstall -y --noninteractive",
+		modeUpgrade:   "flatpak update -y --noninteractive",
+	}
+
+	nativePackage := func(app models.App) string {
+		if target == targetBashFedora {
+			return app.DnfPackage
+		}
+		return app.AptPackage
+	}
+
+	needsFlatpak := false
+	for _, app := range apps {
+		if opts.Mode != modeInstall {
+			break
+		}
+		if nativePackage(app) == "" && app.FlatpakID != "" {
+			needsFlatpak = true
+			break
+		}
+	}
+
+	// The mode, package manager and their commands are fixed strings
+	b := scriptbuilder.New(scriptbuilder.POSIX)
+	b.Raw("#!/usr/bin/env bash")
+	b.Comment(fmt.Sprintf("SetupForMe - Generated %s Script (%s)", text.Title, target))
+	b.Comment("Generated on: " + time.Now().Format("2006-01-02 15:04:05"))
+	b.Blank()
+	b.Raw("set -u", "")
+	b.Raw(fmt.Sprintf("%s_%s() { %s \"$1\"; }", opts.Mode, manager, commands[opts.Mode]))
+	b.Raw(fmt.Sprintf("%s_flatpak() { %s \"$1\"; }", opts.Mode, flatpakCommands[opts.Mode]))
+	b.Raw(shDependencyHelpers...)
+	b.Blank()
+	b.Line("echo %s", "Starting application "+text.Noun+"...")
+	if opts.Mode != modeUninstall {
+		b.Raw(update)
+	}
+	if needsFlatpak {
+		b.Raw("if ! command -v flatpak >/dev/null 2>&1; then " + commands[modeInstall] + " flatpak; fi")
+		b.Raw("flatpak remote-add --if-not-exists flathub https://dl.flathub.org/repo/flathub.flatpakrepo")
+	}
+	b.Blank()
+
+	for i, app := range apps {
+		appName := displayName(app)
+
+		var command scriptbuilder.Code
+		if pkg := nativePackage(app); pkg != "" {
+			command = b.Format("%s_%s %s", scriptbuilder.Code(opts.Mode), scriptbuilder.Code(manager), pkg)
+		} else if app.FlatpakID != "" {
+			command = b.Format("%s_flatpak %s", scriptbuilder.Code(opts.Mode), app.FlatpakID)
+		}
+
+		b.Comment(fmt.Sprintf("App %d: %s", i+1, appName))
+		if command == "" {
+			b.Line("echo %s", "Skipping "+appName+": no package for "+target)
+			b.Blank()
+			continue
+		}
+		shAppStep(b, app, command, opts)
+		b.Blank()
+	}
+
+	if len(apps) == 0 {
+		b.Line("echo %s", "No applications to "+opts.Mode+".")
+	} else {
+		b.Line("echo %s", text.Done)
+	}
+
+	return b.String()
+}
+
+// generateBrewScript renders a macOS script that manages apps with Homebrew
+func generateBrewScript(apps []models.App, opts scriptOptions) string {
+	text := modeText[opts.Mode]
+
+	b := scriptbuilder.New(scriptbuilder.POSIX)
+	b.Raw("#!/usr/bin/env bash")
+	b.Comment(fmt.Sprintf("SetupForMe - Generated %s Script (brew)", text.Title))
+	b.Comment("Generated on: " + time.Now().Format("2006-01-02 15:04:05"))
+	b.Blank()
+	b.Raw("set -u", "")
+	b.Raw("if ! command -v brew >/dev/null 2>&1; then")
+	b.Raw("  echo 'Homebrew is required: https://brew.sh' >&2")
+	b.Raw("  exit 1")
+	b.Raw("fi", "")
+	b.Raw(shDependencyHelpers...)
+	b.Blank()
+	b.Line("echo %s", "Starting application "+text.Noun+"...")
+	if opts.Mode != modeUninstall {
+		b.Raw("brew update")
+	}
+	b.Blank()
+
+	for i, app := range apps {
+		appName := displayName(app)
+
+		b.Comment(fmt.Sprintf("App %d: %s", i+1, appName))
+		if app.BrewPackage == "" {
+			b.Line("echo %s", "Skipping "+appName+": no package for brew")
+			b.Blank()
+			continue
+		}
+
+		command := b.Format("brew %s %s", scriptbuilder.Code(opts.Mode), app.BrewPackage)
+		if app.BrewCask {
+			command = b.Format("brew %s --cask %s", scriptbuilder.Code(opts.Mode), app.BrewPackage)
+		}
+		shAppStep(b, app, command, opts)
+		b.Blank()
+	}
+
+	if len(apps) == 0 {
+		b.Line("echo %s", "No applications to "+opts.Mode+".")
+	} else {
+		b.Line("echo %s", text.Done)
+	}
+
+	return b.String()
+}
+
+// shDependencyHelpers track failed app IDs in a plain string, which also works
+// with the bash 3.2 that ships with macOS
+var shDependencyHelpers = []string{
+	"failed=''",
+	"prerequisite_failed() { for id in \"$@\"; do case \" $failed \" in *\" $id \"*) return 0;; esac; done; return 1; }",
+}
+
+// shAppStep appends the lines that run command for an app, skipping it when
+// a prerequisite failed
+func shAppStep(b *scriptbuilder.Builder, app models.App, command scriptbuilder.Code, opts scriptOptions) {
+	appName := displayName(app)
+	markFailed := b.Format("failed=\"$failed %s\"", app.ID)
+	deps := prerequisites(app, opts)
+
+	b.Line("echo %s", modeText[opts.Mode].Doing+" "+appName+"...")
+	if len(deps) > 0 {
+		b.Line("if prerequisite_failed %s; then echo %s >&2; %s",
+			deps, "Skipping "+appName+": a prerequisite failed", markFailed)
+		b.Line("elif %s; then echo %s; else echo %s >&2; %s; fi",
+			command, "Finished: "+appName, "Failed: "+appName, markFailed)
+		return
+	}
+	b.Line("if %s; then echo %s; else echo %s >&2; %s; fi",
+		command, "Finished: "+appName, "Failed: "+appName, markFailed)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"setupforme/models"
+	"setupforme/scriptbuilder"
+)
+
+// psSkeleton reduces a PowerShell script to its code: comments are dropped
+// and every single-quoted string becomes S. Double-quoted strings are only
+// written by the generator itself, so they are kept as they are.
+func psSkeleton(t *testing.T, script string) string {
+	t.Helper()
+	const singleQuotes = "'\u2018\u2019\u201A\u201B"
+	const doubleQuotes = "\"\u201C\u201D\u201E"
+
+	var out strings.Builder
+	atWordStart := true
+	for script != "" {
+		r, size := utf8.DecodeRuneInString(script)
+		switch {
+		case r == '#' && atWordStart:
+			end := strings.IndexAny(script, "\r\n")
+			if end < 0 {
+				end = len(script)
+			}
+			script = script[end:]
+			continue
+		case strings.ContainsRune(singleQuotes, r):
+			script = script[size:]
+			for {
+				if script == "" {
+					t.Fatal("unterminated single-quoted string")
+				}
+				r, size := utf8.DecodeRuneInString(script)
+				script = script[size:]
+				if !strings.ContainsRune(singleQuotes, r) {
+					continue
+				}
+				next, nextSize := utf8.DecodeRuneInString(script)
+				if script == "" || !strings.ContainsRune(singleQuotes, next) {
+					break
+				}
+				script = script[nextSize:]
+			}
+			out.WriteString("S")
+			atWordStart = false
+			continue
+		case strings.ContainsRune(doubleQuotes, r):
+			end := size
+			for {
+				if end >= len(script) {
+					t.Fatal("unterminated double-quoted string")
+				}
+				r, n := utf8.DecodeRuneInString(script[end:])
+				end += n
+				if r == '`' {
+					_, n = utf8.DecodeRuneInString(script[end:])
+					end += n
+				} else if strings.ContainsRune(doubleQuotes, r) {
+					break
+				}
+			}
+			out.WriteString(script[:end])
+			script = script[end:]
+			atWordStart = false
+			continue
+		}
+		out.WriteRune(r)
+		script = script[size:]
+		atWordStart = unicode.IsSpace(r)
+	}
+	return out.String()
+}
+
+// shSkeleton reduces a POSIX shell script like psSkeleton does. A word made
+// of several single-quoted parts joined by \' becomes one S.
+func shSkeleton(t *testing.T, script string) string {
+	t.Helper()
+	var out strings.Builder
+	atWordStart := true
+	for script != "" {
+		c := script[0]
+		switch {
+		case c == '#' && atWordStart:
+			end := strings.IndexByte(script, '\n')
+			if end < 0 {
+				end = len(script)
+			}
+			script = script[end:]
+			continue
+		case c == '\'':
+			end := strings.IndexByte(script[1:], '\'')
+			if end < 0 {
+				t.Fatal("unterminated single-quoted string")
+			}
+			script = script[end+2:]
+			out.WriteString("S")
+			atWordStart = false
+			continue
+		case c == '\\' && len(script) > 1:
+			out.WriteString(script[:2])
+			script = script[2:]
+			atWordStart = false
+			continue
+		case c == '"':
+			end := 1
+			for ; end < len(script) && script[end] != '"'; end++ {
+				if script[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(script) {
+				t.Fatal("unterminated double-quoted string")
+			}
+			out.WriteString(script[:end+1])
+			script = script[end+1:]
+			atWordStart = false
+			continue
+		}
+		out.WriteByte(c)
+		script = script[1:]
+		atWordStart = c == ' ' || c == '\t' || c == '\n' || c == ';'
+	}
+	skeleton := out.String()
+	for strings.Contains(skeleton, `S\'S`) {
+		skeleton = strings.ReplaceAll(skeleton, `S\'S`, "S")
+	}
+	return skeleton
+}
+
+// fuzzApps returns apps whose user-controlled fields hold the given values
+func fuzzApps(name, args, value string) []models.App {
+	return []models.App{
+		{ID: 1, Name: name, WingetID: value, Args: args, Version: value, Scope: scopeMachine,
+			AptPackage: value, DnfPackage: value, FlatpakID: value, BrewPackage: value, RebootBehavior: rebootDefer},
+		{ID: 2, Name: name, DownloadURL: value, InstallerType: "exe-unknown", SHA256: value, Args: args,
+			UninstallCommand: value, FlatpakID: value, BrewPackage: value, BrewCask: true, DependsOn: []int{1}},
+		{ID: 3, Name: name},
+	}
+}
+
+// benign replaces a fuzzed value with a harmless one that takes the same
+// branches in the generator
+func benign(v string) string {
+	switch {
+	case v == "":
+		return ""
+	case strings.Contains(strings.ToLower(v), "msiexec"):
+		return "msiexec"
+	}
+	return "x"
+}
+
+// benignArgs returns harmless installer args that split into as many
+// arguments as args, since each becomes an element of a PowerShell array
+func benignArgs(args string) string {
+	return strings.TrimSpace(strings.Repeat("x ", len(scriptbuilder.SplitArgs(args))))
+}
+
+// firstDiff shows where two skeletons start to differ
+func firstDiff(got, want string) string {
+	i := 0
+	for i < len(got) && i < len(want) && got[i] == want[i] {
+		i++
+	}
+	start := max(i-80, 0)
+	return "got:  ..." + got[start:min(i+80, len(got))] + "\nwant: ..." + want[start:min(i+80, len(want))]
+}
+
+// FuzzGeneratedScripts checks that user-controlled values only ever end up
+// inside string literals and comments: the generated script must have the
+// same code as one generated from harmless values.
+func FuzzGeneratedScripts(f *testing.F) {
+	seeds := []string{
+		"Git's",
+		"'; Remove-Item -Recurse C:\\; '",
+		"\u2019; Start-Process calc; \u2018",
+		"$(Start-Process calc) `\"",
+		"x\n# not a comment\nRemove-Item C:\\",
+		"'\\''; rm -rf ~; echo '",
+		"$(rm -rf ~) \"; reboot; \"",
+		`INSTALLDIR="C:\Program Files\App" /S`,
+	}
+	for _, s := range seeds {
+		f.Add(s, s, s)
+	}
+	f.Add("Visual Studio Code", "--override \"/VERYSILENT /MERGETASKS=!runcode\"", "Microsoft.VisualStudioCode")
+
+	f.Fuzz(func(t *testing.T, name, args, value string) {
+		if !utf8.ValidString(name) || !utf8.ValidString(args) || !utf8.ValidString(value) {
+			t.Skip()
+		}
+		// Postgres text columns cannot store NUL, so stored apps never hold one
+		name = strings.ReplaceAll(name, "\x00", "")
+		args = strings.ReplaceAll(args, "\x00", "")
+		value = strings.ReplaceAll(value, "\x00", "")
+
+		fuzzed := fuzzApps(name, args, value)
+		safe := fuzzApps(benign(name), benignArgs(args), benign(value))
+
+		for target, generate := range scriptGenerators {
+			for mode := range modeText {
+				variants := []map[string][]string{{"target": {target}, "mode": {mode}}}
+				if target == targetPowerShell {
+					variants = append(variants, map[string][]string{"target": {target}, "mode": {mode}, "parallel": {"true"}})
+				}
+				for _, q := range variants {
+					opts, err := parseScriptOptions(q)
+					if err != nil {
+						t.Fatal(err)
+					}
+					safeOpts := opts
+					if target == targetPowerShell {
+						opts.ReportURL, opts.ReportToken = value, value
+						safeOpts.ReportURL, safeOpts.ReportToken = benign(value), benign(value)
+					}
+
+					got, want := generate(fuzzed, opts), generate(safe, safeOpts)
+					skeleton := shSkeleton
+					if target == targetPowerShell {
+						skeleton = psSkeleton
+					}
+					if g, w := skeleton(t, got), skeleton(t, want); g != w {
+						t.Fatalf("%s %s script changes shape for name=%q args=%q value=%q:\n%s", target, mode, name, args, value, firstDiff(g, w))
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"setupforme/models"
+
+	"github.com/lib/pq"
+)
+
+// Profile visibilities
+const (
+	visibilityPrivate  = "private"
+	visibilityUnlisted = "unlisted" // readable by anyone with the slug
+	visibilityPublic   = "public"   // readable and listed under /api/shared
+)
+
+// Bounds for slugs and the shared profile listing
+const (
+	maxSlugNameLength  = 40
+	slugSuffixBytes    = 5 // 8 base32 characters
+	defaultSharedLimit = 20
+	maxSharedLimit     = 100
+)
+
+// appCopyColumns lists the apps columns a fork copies from the upstream apps
+const appCopyColumns = `name, winget_id, winget_id_resolution, download_url, sha256, installer_type, uninstall_command,
+	args, version, install_policy, scope, requires_admin, reboot_behavior, apt_package, dnf_package, flatpak_id, brew_package, brew_cask,
+	` + metadataColumns
+
+var slugEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newProfileSlug derives a URL slug from a profile name. The random suffix
+// keeps slugs unique and unlisted profiles hard to guess.
+func newProfileSlug(name string) (string, error) {
+	suffix := make([]byte, slugSuffixBytes)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	// Keep ASCII letters and digits, joining the words in between with dashes
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	slug := b.String()
+	if len(slug) > maxSlugNameLength {
+		slug = slug[:maxSlugNameLength]
+	}
+	slug = strings.TrimRight(slug, "-")
+	if slug != "" {
+		slug += "-"
+	}
+	return slug + strings.ToLower(slugEncoding.EncodeToString(suffix)), nil
+}
+
+// sharedProfile loads the profile shared under slug together with its owner
+func (h *AppHandler) sharedProfile(slug string) (int, int, models.SharedProfile, error) {
+	var profileID, ownerID int
+	profile := models.SharedProfile{Slug: slug}
+	err := h.db.QueryRow(`
+		SELECT p.id, p.user_id, p.name, p.description, p.visibility, COUNT(a.id)
+		FROM profiles p
+		LEFT JOIN apps a ON a.profile_id = p.id
+		WHERE p.slug = $1 AND p.visibility <> $2
+		GROUP BY p.id
+	`, slug, visibilityPrivate).Scan(&profileID, &ownerID, &profile.Name, &profile.Description, &profile.Visibility, &profile.AppCount)
+	return profileID, ownerID, profile, err
+}
+
+// sharedScriptOptions parses the script query parameters for a shared
+// profile. Reporting is refused because runs belong to the profile's owner.
+func sharedScriptOptions(r *http.Request, profileID int) (scriptOptions, error) {
+	opts, err := parseScriptOptions(r.URL.Query())
+	if err != nil {
+		return opts, err
+	}
+	if opts.Report {
+		return opts, errors.New("report is not available for shared profiles")
+	}
+	opts.ProfileID = profileID
+	return opts, nil
+}
+
+// ListSharedProfiles lists public profiles, newest first, paged with limit
+// and offset
+func (h *AppHandler) ListSharedProfiles(w http.ResponseWriter, r *http.Request) {
+	limit, offset := defaultSharedLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSharedLimit {
+			writeErrorResponse(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	rows, err := h.db.Query(`
+		SELECT p.slug, p.name, p.description, p.visibility, COUNT(a.id)
+		FROM profiles p
+		LEFT JOIN apps a ON a.profile_id = p.id
+		WHERE p.visibility = $1
+		GROUP BY p.id
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`, visibilityPublic, limit, offset)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profiles")
+		return
+	}
+	defer rows.Close()
+
+	profiles := []models.SharedProfile{}
+	for rows.Next() {
+		var p models.SharedProfile
+		if err := rows.Scan(&p.Slug, &p.Name, &p.Description, &p.Visibility, &p.AppCount); err != nil {
+			writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profiles")
+			return
+		}
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profiles")
+		return
+	}
+
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// GetSharedProfile returns an unlisted or public profile with its apps and
+// script. It takes the same query parameters as GET /api/apps/script, except
+// report.
+func (h *AppHandler) GetSharedProfile(w http.ResponseWriter, r *http.Request) {
+	profileID, ownerID, profile, err := h.sharedProfile(r.PathValue("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	opts, err := sharedScriptOptions(r, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	apps, err := h.fetchApps(ownerID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+	profile.Apps = []models.App{}
+	for _, app := range apps {
+		app.UserID, app.ProfileID = 0, 0
+		profile.Apps = append(profile.Apps, app)
+	}
+
+	profile.Script, err = h.renderScript(ownerID, opts, publicBaseURL(r))
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate script")
+		return
+	}
+	profile.Target = opts.Target
+
+	json.NewEncoder(w).Encode(profile)
+}
+
+// ServeSharedScript serves the script of an unlisted or public profile as
+// plain text, for piping straight into a shell
+func (h *AppHandler) ServeSharedScript(w http.ResponseWriter, r *http.Request) {
+	profileID, ownerID, _, err := h.sharedProfile(r.PathValue("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	opts, err := sharedScriptOptions(r, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	script, err := h.renderScript(ownerID, opts, publicBaseURL(r))
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to generate script")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(script))
+}
+
+// ForkProfile copies a shared profile and its apps into a new profile of the
+// caller. The copy remembers the upstream profile and apps it came from so it
+// can be synced with them later.
+func (h *AppHandler) ForkProfile(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	upstreamID, _, upstream, err := h.sharedProfile(r.PathValue("slug"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeErrorResponse(w, http.StatusNotFound, "Profile not found")
+		} else {
+			writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+
+	// The body is optional
+	var req models.ForkProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = upstream.Name
+	}
+	if msg := validateProfileName(req.Name); msg != "" {
+		writeErrorResponse(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var profileID int
+	err = tx.QueryRow(`
+		INSERT INTO profiles (user_id, name, description, is_default, upstream_profile_id, forked_at)
+		VALUES ($1, $2, $3, NOT EXISTS (SELECT 1 FROM profiles WHERE user_id = $1 AND is_default), $4, NOW())
+		RETURNING id
+	`, userID, req.Name, upstream.Description, upstreamID).Scan(&profileID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			writeErrorResponse(w, http.StatusConflict, fmt.Sprintf("You already have a profile named %q; fork it under another name", req.Name))
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fork profile")
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO apps (user_id, profile_id, upstream_app_id, `+appCopyColumns+`)
+		SELECT $1, $2, id, `+appCopyColumns+`
+		FROM apps WHERE profile_id = $3
+		ORDER BY id
+	`, userID, profileID, upstreamID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fork profile")
+		return
+	}
+
+	// Point the copied dependencies at the copied apps
+	_, err = tx.Exec(`
+		INSERT INTO app_dependencies (app_id, depends_on_id)
+		SELECT a.id, b.id
+		FROM app_dependencies d
+		JOIN apps a ON a.upstream_app_id = d.app_id AND a.profile_id = $1
+		JOIN apps b ON b.upstream_app_id = d.depends_on_id AND b.profile_id = $1
+	`, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fork profile")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fork profile")
+		return
+	}
+
+	profiles, err := h.fetchProfiles(userID, profileID)
+	if err != nil || len(profiles) == 0 {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+	profile := profiles[0]
+
+	profile.Apps, err = h.fetchApps(userID, profileID)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+// UnknownPackageResponse is returned with 400 when winget_id names no package
+type UnknownPackageResponse struct {
+	Error       string                `json:"error"`
+	Message     string                `json:"message"`
+	Suggestions []ResolutionCandidate `json:"suggestions"`
+}
+
+// Outcomes of re-checking a stored winget_id
+const (
+	validationOK         = "ok"
+	validationRemoved    = "removed"    // the package source no longer has the package
+	validationUnverified = "unverified" // the package source could not be asked
+)
+
+type AppValidation struct {
+	AppID       int                   `json:"app_id"`
+	Name        string                `json:"name"`
+	WingetID    string                `json:"winget_id"`
+	Status      string                `json:"status"`
+	CanonicalID string                `json:"canonical_id,omitempty"` // set when the stored ID differs in case
+	Suggestions []ResolutionCandidate `json:"suggestions,omitempty"`
+}
+
+type AppValidationResponse struct {
+	Checked    int             `json:"checked"`
+	Removed    int             `json:"removed"`
+	Unverified int             `json:"unverified"`
+	Apps       []AppValidation `json:"apps"`
+}
+
+// checkWingetPackage verifies a new or changed winget_id against the package
+// source, matching it case-insensitively and storing the package's own
+// spelling, and checks that a pinned version is published. An ID that cannot
+// be checked right now is accepted unless a version is pinned. It writes the
+// error response and returns false when the app cannot be saved. Microsoft
+// Store IDs are accepted as they are, since the package source only knows
+// the winget source.
+func (h *AppHandler) checkWingetPackage(w http.ResponseWriter, req *models.CreateAppRequest, changed bool) bool {
+	if req.WingetID == "" || (!changed && req.Version == "") || utils.SourceForID(req.WingetID) == utils.SourceMSStore {
+		return true
+	}
+
+	pkg, err := h.source.Details(req.WingetID)
+	switch {
+	case err == nil:
+	case errors.Is(err, utils.ErrPackageNotFound) && changed:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(UnknownPackageResponse{
+			Error:       http.StatusText(http.StatusBadRequest),
+			Message:     "Unknown winget_id " + req.WingetID,
+			Suggestions: h.suggestPackages(req.WingetID),
+		})
+		return false
+	case errors.Is(err, utils.ErrPackageNotFound):
+		writeErrorResponse(w, http.StatusBadRequest, "Cannot pin a version: package "+req.WingetID+" not found")
+		return false
+	case req.Version == "":
+		log.Printf("winget_id %s accepted without checking: %v", req.WingetID, err)
+		return true
+	case errors.Is(err, utils.ErrSourceUnavailable):
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Cannot verify the pinned version right now, try again later")
+		return false
+	default:
+		writeErrorResponse(w, http.StatusBadGateway, "Could not verify the pinned version")
+		return false
+	}
+
+	req.WingetID = pkg.Id
+	if req.Version == "" {
+		return true
+	}
+	for _, v := range pkg.Versions {
+		if v == req.Version {
+			return true
+		}
+	}
+
+	msg := "Version " + req.Version + " is not available for " + req.WingetID
+	if len(pkg.Versions) > 0 {
+		msg += " (latest is " + pkg.Versions[0] + ")"
+	}
+	writeErrorResponse(w, http.StatusBadRequest, msg)
+	return false
+}
+
+// errNotInSource is returned by packageDetails for Microsoft Store IDs
+var errNotInSource = errors.New("Microsoft Store packages are not in the package source")
+
+// packageDetails looks up a package in the package source, which only covers
+// the winget source. Microsoft Store IDs fail with errNotInSource without a
+// lookup instead of coming back as unknown packages.
+func (h *AppHandler) packageDetails(id string) (utils.WingetPackage, error) {
+	if utils.SourceForID(id) == utils.SourceMSStore {
+		return utils.WingetPackage{}, errNotInSource
+	}
+	return h.source.Details(id)
+}
+
+// suggestPackages looks for packages an unknown winget_id may have meant,
+// best first. It searches for the ID's words and, failing that, its last
+// segment, which is usually the product name.
+func (h *AppHandler) suggestPackages(wingetID string) []ResolutionCandidate {
+	queries := []string{strings.ReplaceAll(wingetID, ".", " ")}
+	if i := strings.LastIndex(wingetID, "."); i >= 0 && i < len(wingetID)-1 {
+		queries = append(queries, wingetID[i+1:])
+	}
+
+	for _, q := range queries {
+		packages, err := h.source.Search(q, resolveCandidates)
+		if err == nil && len(packages) > 0 {
+			return rankCandidates(wingetID, packages)
+		}
+	}
+	return []ResolutionCandidate{}
+}
+
+// ValidateApps re-checks the winget_id of every stored app against the
+// package source and flags packages that have been removed from it
+func (h *AppHandler) ValidateApps(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+
+	apps, err := h.fetchApps(userID, 0)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to fetch apps")
+		return
+	}
+
+	resp := AppValidationResponse{Apps: []AppValidation{}}
+	for _, app := range apps {
+		if app.WingetID == "" {
+			continue
+		}
+
+		result := AppValidation{AppID: app.ID, Name: app.Name, WingetID: app.WingetID, Status: validationOK}
+		pkg, err := h.packageDetails(app.WingetID)
+		switch {
+		case err == nil:
+			if pkg.Id != app.WingetID {
+				result.CanonicalID = pkg.Id
+			}
+		case errors.Is(err, utils.ErrPackageNotFound):
+			result.Status = validationRemoved
+			result.Suggestions = h.suggestPackages(app.WingetID)
+			resp.Removed++
+		default:
+			result.Status = validationUnverified
+			resp.Unverified++
+		}
+
+		resp.Checked++
+		resp.Apps = append(resp.Apps, result)
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"setupforme/models"
+	"setupforme/utils"
+)
+
+// notFoundSource knows no packages and counts the lookups made
+type notFoundSource struct{ lookups int }
+
+func (s *notFoundSource) Search(string, int) ([]utils.WingetPackage, error) {
+	s.lookups++
+	return nil, utils.ErrPackageNotFound
+}
+
+func (s *notFoundSource) Resolve(string) (string, error) {
+	s.lookups++
+	return "", utils.ErrPackageNotFound
+}
+
+func (s *notFoundSource) Details(string) (utils.WingetPackage, error) {
+	s.lookups++
+	return utils.WingetPackage{}, utils.ErrPackageNotFound
+}
+
+// Imports accept Microsoft Store IDs, so creating or updating an app must too,
+// even though the package source cannot look them up
+func TestCheckWingetPackageAcceptsMSStoreIDs(t *testing.T) {
+	source := &notFoundSource{}
+	h := &AppHandler{source: source}
+
+	req := models.CreateAppRequest{Name: "WhatsApp", WingetID: "9NKSQGP7F2NH"}
+	w := httptest.NewRecorder()
+	if !h.checkWingetPackage(w, &req, true) {
+		t.Fatalf("msstore ID rejected: %d %s", w.Code, w.Body)
+	}
+	if source.lookups != 0 {
+		t.Errorf("package source was asked %d times for an msstore ID", source.lookups)
+	}
+
+	req = models.CreateAppRequest{Name: "Nothing", WingetID: "Nobody.Nothing"}
+	w = httptest.NewRecorder()
+	if h.checkWingetPackage(w, &req, true) || w.Code != 400 {
+		t.Errorf("unknown winget ID accepted (status %d)", w.Code)
+	}
+}
@@ -2,34 +2,188 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
 	"setupforme/utils"
 )
 
+// WingetHandler serves winget package lookups from a package source
+type WingetHandler struct {
+	source utils.PackageSource
+	cache  *utils.CachedSource // reported by CacheStats
+}
+
+func NewWingetHandler(source utils.PackageSource, cache *utils.CachedSource) *WingetHandler {
+	return &WingetHandler{source: source, cache: cache}
+}
+
+// writeSourceError replies to a failed package lookup
+func writeSourceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrPackageNotFound):
+		writeErrorResponse(w, http.StatusNotFound, "Package not found")
+	case errors.Is(err, utils.ErrSourceUnavailable):
+		writeErrorResponse(w, http.StatusServiceUnavailable, "Package lookups are temporarily unavailable")
+	default:
+		writeErrorResponse(w, http.StatusBadGateway, "Package lookup failed")
+	}
+}
+
 type WingetSearchResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Publisher string `json:"publisher"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Publisher   string `json:"publisher"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"` // latest published version
 }
 
-// WingetSearchHandler searches winget.run for a given query and returns top results
-func WingetSearchHandler(w http.ResponseWriter, r *http.Request) {
+// Paging bounds for winget search. Results are ranked across the first
+// maxSearchCandidates matches, then paged.
+const (
+	defaultSearchLimit  = 10
+	maxSearchLimit      = 50
+	maxSearchCandidates = 100
+)
+
+// Search looks up winget packages for a given query and returns the ranked
+// matches, paged with limit and offset. The X-Total-Count header holds the
+// number of matches before paging.
+func (h *WingetHandler) Search(w http.ResponseWriter, r *http.Request) {
 	q := strings.TrimSpace(r.URL.Query().Get("q"))
 	if q == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "missing q"})
+		writeErrorResponse(w, http.StatusBadRequest, "missing q")
+		return
+	}
+
+	limit, offset := defaultSearchLimit, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeErrorResponse(w, http.StatusBadRequest, "limit must be between 1 and 50")
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	packages, err := h.source.Search(q, maxSearchCandidates)
+	if err != nil && !errors.Is(err, utils.ErrPackageNotFound) {
+		writeSourceError(w, err)
+		return
+	}
+	rankPackages(q, packages)
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(packages)))
+
+	results := []WingetSearchResponse{}
+	for i := offset; i < len(packages) && i < offset+limit; i++ {
+		pkg := packages[i]
+		result := WingetSearchResponse{
+			ID:          pkg.Id,
+			Name:        pkg.Latest.Name,
+			Publisher:   pkg.Latest.Publisher,
+			Description: pkg.Latest.Description,
+		}
+		if len(pkg.Versions) > 0 {
+			result.Version = pkg.Versions[0]
+		}
+		results = append(results, result)
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+// rankPackages orders search matches by how well they fit the query: an
+// exact ID match first, then an exact name, a name prefix and a publisher
+// match. Ties keep the order of the search backend.
+func rankPackages(query string, packages []utils.WingetPackage) {
+	query = strings.ToLower(query)
+	rank := func(pkg utils.WingetPackage) int {
+		name := strings.ToLower(pkg.Latest.Name)
+		publisher := strings.ToLower(pkg.Latest.Publisher)
+		switch {
+		case strings.ToLower(pkg.Id) == query:
+			return 0
+		case name == query:
+			return 1
+		case strings.HasPrefix(name, query):
+			return 2
+		case publisher == query || strings.HasPrefix(publisher, query):
+			return 3
+		}
+		return 4
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return rank(packages[i]) < rank(packages[j])
+	})
+}
+
+type WingetVersionsResponse struct {
+	ID       string   `json:"id"`
+	Versions []string `json:"versions"`
+}
+
+// Versions lists the published versions of a winget package, newest first
+func (h *WingetHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.PathValue("id"))
+	if !utils.IsValidWingetID(id) {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid package ID")
 		return
 	}
 
-	// For now, resolve only the top id; could be extended to return multiple
-	id, err := utils.ResolveWingetID(q)
-	if err != nil || id == "" {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"message": "no package found"})
+	pkg, err := h.source.Details(id)
+	if err != nil {
+		writeSourceError(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode([]WingetSearchResponse{{ID: id, Name: q}})
+	versions := pkg.Versions
+	if versions == nil {
+		versions = []string{}
+	}
+	json.NewEncoder(w).Encode(WingetVersionsResponse{ID: id, Versions: versions})
+}
+
+type WingetCacheStatsResponse struct {
+	Hits           int64   `json:"hits"`
+	StaleHits      int64   `json:"stale_hits"`
+	NegativeHits   int64   `json:"negative_hits"`
+	Misses         int64   `json:"misses"`
+	Coalesced      int64   `json:"coalesced"`
+	UpstreamErrors int64   `json:"upstream_errors"`
+	Entries        int     `json:"entries"`
+	HitRate        float64 `json:"hit_rate"` // share of lookups answered without waiting for winget.run
+}
+
+// CacheStats reports how many winget.run lookups were answered from the cache
+func (h *WingetHandler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	stats := h.cache.Stats()
+
+	resp := WingetCacheStatsResponse{
+		Hits:           stats.Hits,
+		StaleHits:      stats.StaleHits,
+		NegativeHits:   stats.NegativeHits,
+		Misses:         stats.Misses,
+		Coalesced:      stats.Coalesced,
+		UpstreamErrors: stats.UpstreamErrors,
+		Entries:        stats.Entries,
+	}
+	served := stats.Hits + stats.StaleHits + stats.NegativeHits
+	if total := served + stats.Misses; total > 0 {
+		resp.HitRate = float64(served) / float64(total)
+	}
+
+	json.NewEncoder(w).Encode(resp)
 }
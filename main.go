@@ -6,9 +6,11 @@ import (
 	"net/http"
 	"os"
 
+	"setupforme/catalog"
 	"setupforme/database"
 	"setupforme/handlers"
 	"setupforme/middleware"
+	"setupforme/utils"
 )
 
 func main() {
@@ -21,7 +23,15 @@ func main() {
 
 	// Initialize handlers with database
 	authHandler := handlers.NewAuthHandler(db)
-	appHandler := handlers.NewAppHandler(db)
+	// Winget lookups use the offline catalog once imported, and a cached winget.run otherwise
+	wingetCache := utils.NewCachedSource(utils.NewWingetRunSource(utils.WingetRunAPI, nil))
+	packageSource := catalog.NewSource(db, wingetCache)
+
+	appHandler := handlers.NewAppHandler(db, packageSource)
+	wingetHandler := handlers.NewWingetHandler(packageSource, wingetCache)
+
+	// Keep the package metadata shown with each app current
+	appHandler.StartMetadataRefresh()
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -31,14 +41,56 @@ func main() {
 	mux.HandleFunc("POST /api/auth/login", authHandler.Login)
 
 	// Winget search route (unauthenticated is fine for suggestions)
-	mux.HandleFunc("GET /api/winget/search", handlers.WingetSearchHandler)
+	mux.HandleFunc("GET /api/winget/search", wingetHandler.Search)
+	mux.HandleFunc("GET /api/winget/packages/{id}/versions", wingetHandler.Versions)
+	mux.HandleFunc("GET /api/winget/cache", wingetHandler.CacheStats)
 
 	// Protected app routes
 	mux.Handle("GET /api/apps", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetApps)))
 	mux.Handle("POST /api/apps", middleware.AuthMiddleware(http.HandlerFunc(appHandler.CreateApp)))
 	mux.Handle("PUT /api/apps/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.UpdateApp)))
 	mux.Handle("DELETE /api/apps/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.DeleteApp)))
+	mux.Handle("POST /api/apps/{id}/checksum", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ComputeChecksum)))
+	mux.Handle("POST /api/apps/{id}/detect", middleware.AuthMiddleware(http.HandlerFunc(appHandler.DetectInstallerType)))
+	mux.Handle("GET /api/apps/validate", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ValidateApps)))
 	mux.Handle("GET /api/apps/script", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GenerateScript)))
+	mux.Handle("GET /api/apps/configuration", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ExportConfiguration)))
+	mux.Handle("POST /api/apps/configuration", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ImportConfiguration)))
+	mux.Handle("GET /api/apps/packages", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ExportPackages)))
+	mux.Handle("POST /api/apps/packages", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ImportPackages)))
+
+	// Named setup profiles grouping the user's apps
+	mux.Handle("GET /api/profiles", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetProfiles)))
+	mux.Handle("POST /api/profiles", middleware.AuthMiddleware(http.HandlerFunc(appHandler.CreateProfile)))
+	mux.Handle("GET /api/profiles/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetProfile)))
+	mux.Handle("PUT /api/profiles/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.UpdateProfile)))
+	mux.Handle("DELETE /api/profiles/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.DeleteProfile)))
+	mux.Handle("GET /api/profiles/{id}/script", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ProfileScript)))
+
+	// Shared profiles (reading needs no login, forking does)
+	mux.HandleFunc("GET /api/shared", appHandler.ListSharedProfiles)
+	mux.HandleFunc("GET /api/shared/{slug}", appHandler.GetSharedProfile)
+	mux.Handle("POST /api/shared/{slug}/fork", middleware.AuthMiddleware(http.HandlerFunc(appHandler.ForkProfile)))
+	mux.HandleFunc("GET /p/{slug}", appHandler.ServeSharedScript)
+
+	// Curated bundles (browsing needs no login, changing them needs an admin)
+	mux.HandleFunc("GET /api/bundles", appHandler.GetBundles)
+	mux.HandleFunc("GET /api/bundles/{slug}", appHandler.GetBundle)
+	mux.Handle("POST /api/bundles/{slug}/install", middleware.AuthMiddleware(http.HandlerFunc(appHandler.InstallBundle)))
+	mux.Handle("POST /api/bundles", middleware.AuthMiddleware(middleware.AdminMiddleware(db, http.HandlerFunc(appHandler.CreateBundle))))
+	mux.Handle("PUT /api/bundles/{slug}", middleware.AuthMiddleware(middleware.AdminMiddleware(db, http.HandlerFunc(appHandler.UpdateBundle))))
+	mux.Handle("DELETE /api/bundles/{slug}", middleware.AuthMiddleware(middleware.AdminMiddleware(db, http.HandlerFunc(appHandler.DeleteBundle))))
+
+	// Shareable script links
+	mux.Handle("POST /api/links", middleware.AuthMiddleware(http.HandlerFunc(appHandler.CreateLink)))
+	mux.Handle("GET /api/links", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetLinks)))
+	mux.Handle("DELETE /api/links/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.RevokeLink)))
+	mux.HandleFunc("GET /s/{token}", appHandler.ServeLink)
+
+	// Install runs reported by generated scripts (results use the run token, not a JWT)
+	mux.Handle("GET /api/runs", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetRuns)))
+	mux.Handle("GET /api/runs/{id}", middleware.AuthMiddleware(http.HandlerFunc(appHandler.GetRun)))
+	mux.HandleFunc("POST /api/runs/{id}/results", appHandler.ReportResult)
 
 	// CORS middleware
 	handler := middleware.CORSMiddleware(mux)
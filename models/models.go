@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
 	ID       int    `json:"id"`
 	Email    string `json:"email"`
@@ -7,12 +9,53 @@ type User struct {
 }
 
 type App struct {
-	ID          int    `json:"id"`
-	UserID      int    `json:"user_id"`
-	Name        string `json:"name"`
-	WingetID    string `json:"winget_id,omitempty"`
-	DownloadURL string `json:"download_url,omitempty"`
-	Args        string `json:"args,omitempty"`
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id,omitempty"`    // Left out of shared profiles
+	ProfileID int    `json:"profile_id,omitempty"` // Left out of shared profiles
+	Name      string `json:"name"`
+	WingetID  string `json:"winget_id,omitempty"`
+	// WingetIDResolution records how winget_id was chosen: auto when it was
+	// resolved from the name without asking, confirmed when the user gave it
+	WingetIDResolution string `json:"winget_id_resolution,omitempty"`
+	DownloadURL        string `json:"download_url,omitempty"`
+	SHA256             string `json:"sha256,omitempty"`
+	// InstallerType is the download_url installer's format: msi, nsis, inno,
+	// wix-burn, exe-unknown, msix or zip. It picks silent switches when args is empty.
+	InstallerType string `json:"installer_type,omitempty"`
+	// UninstallCommand removes a download_url app (e.g. "msiexec /x {GUID} /qn")
+	UninstallCommand string `json:"uninstall_command,omitempty"`
+	Args             string `json:"args,omitempty"`
+	Version          string `json:"version,omitempty"`
+	// InstallPolicy decides what to do when the package is already installed: skip, upgrade or force
+	InstallPolicy string `json:"install_policy,omitempty"`
+	// Scope is passed to winget as --scope (user or machine). Machine scope
+	// and RequiresAdmin make the PowerShell script elevate for this app.
+	Scope         string `json:"scope,omitempty"`
+	RequiresAdmin bool   `json:"requires_admin,omitempty"`
+	// RebootBehavior decides what the PowerShell script does when the
+	// installer asks for a restart: ignore (default), defer or immediate
+	RebootBehavior string `json:"reboot_behavior,omitempty"`
+	// Per-platform package identifiers used by the non-Windows script targets
+	AptPackage  string `json:"apt_package,omitempty"`
+	DnfPackage  string `json:"dnf_package,omitempty"`
+	FlatpakID   string `json:"flatpak_id,omitempty"`
+	BrewPackage string `json:"brew_package,omitempty"`
+	BrewCask    bool   `json:"brew_cask,omitempty"`
+	DependsOn   []int  `json:"depends_on,omitempty"` // IDs of apps that must be installed first
+
+	PackageMetadata
+}
+
+// PackageMetadata describes an app's winget package. It is filled in from the
+// package source when winget_id is set and refreshed in the background.
+type PackageMetadata struct {
+	Publisher     string     `json:"publisher,omitempty"`
+	Homepage      string     `json:"homepage,omitempty"`
+	License       string     `json:"license,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	IconURL       string     `json:"icon_url,omitempty"`
+	LatestVersion string     `json:"latest_version,omitempty"`
+	EnrichedAt    *time.Time `json:"enriched_at,omitempty"`
 }
 
 type LoginRequest struct {
@@ -30,18 +73,195 @@ type AuthResponse struct {
 	User  User   `json:"user"`
 }
 
+// CreateAppRequest holds the editable fields of an app. Each field means the
+// same as the App field of the same name; see App for their documentation.
 type CreateAppRequest struct {
+	Name             string `json:"name"`
+	WingetID         string `json:"winget_id,omitempty"`
+	DownloadURL      string `json:"download_url,omitempty"`
+	SHA256           string `json:"sha256,omitempty"`
+	InstallerType    string `json:"installer_type,omitempty"`
+	UninstallCommand string `json:"uninstall_command,omitempty"`
+	Args             string `json:"args,omitempty"`
+	Version          string `json:"version,omitempty"`
+	InstallPolicy    string `json:"install_policy,omitempty"`
+	Scope            string `json:"scope,omitempty"`
+	RequiresAdmin    bool   `json:"requires_admin,omitempty"`
+	RebootBehavior   string `json:"reboot_behavior,omitempty"`
+	AptPackage       string `json:"apt_package,omitempty"`
+	DnfPackage       string `json:"dnf_package,omitempty"`
+	FlatpakID        string `json:"flatpak_id,omitempty"`
+	BrewPackage      string `json:"brew_package,omitempty"`
+	BrewCask         bool   `json:"brew_cask,omitempty"`
+	DependsOn        []int  `json:"depends_on,omitempty"`
+	// ProfileID puts the app in one of the user's profiles (the default profile when 0)
+	ProfileID int `json:"profile_id,omitempty"`
+}
+
+// UpdateAppRequest replaces all editable fields of an app, so it takes the
+// same fields as CreateAppRequest
+type UpdateAppRequest = CreateAppRequest
+
+// Profile is a named list of apps, e.g. one per machine a user sets up
+type Profile struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	IsDefault   bool   `json:"is_default"`
+	// Visibility is private, unlisted (readable by anyone with the slug) or
+	// public (also listed under /api/shared)
+	Visibility string `json:"visibility"`
+	// Slug names the profile under /api/shared once it is first shared and
+	// stays the same afterwards
+	Slug string `json:"slug,omitempty"`
+	// UpstreamSlug and ForkedAt are set on a profile forked from a shared one
+	UpstreamSlug string     `json:"upstream_slug,omitempty"`
+	ForkedAt     *time.Time `json:"forked_at,omitempty"`
+	AppCount     int        `json:"app_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Apps         []App      `json:"apps,omitempty"`
+}
+
+type CreateProfileRequest struct {
 	Name        string `json:"name"`
-	WingetID    string `json:"winget_id,omitempty"`
-	DownloadURL string `json:"download_url,omitempty"`
-	Args        string `json:"args,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
-type UpdateAppRequest struct {
+type UpdateProfileRequest struct {
 	Name        string `json:"name"`
-	WingetID    string `json:"winget_id,omitempty"`
-	DownloadURL string `json:"download_url,omitempty"`
-	Args        string `json:"args,omitempty"`
+	Description string `json:"description,omitempty"`
+	IsDefault   bool   `json:"is_default,omitempty"` // true makes this the default profile
+	Visibility  string `json:"visibility,omitempty"` // unchanged when empty
+}
+
+// SharedProfile is a profile as anyone with its slug sees it
+type SharedProfile struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Visibility  string `json:"visibility"`
+	AppCount    int    `json:"app_count"`
+	Apps        []App  `json:"apps,omitempty"`   // Only included for a single profile
+	Script      string `json:"script,omitempty"` // Only included for a single profile
+	Target      string `json:"target,omitempty"`
+}
+
+type ForkProfileRequest struct {
+	Name string `json:"name,omitempty"` // defaults to the shared profile's name
+}
+
+// Bundle is a curated list of packages, e.g. "Web developer", that users can
+// add to their apps in one call
+type Bundle struct {
+	ID          int             `json:"id"`
+	Slug        string          `json:"slug"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Packages    []BundlePackage `json:"packages"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// BundlePackage is a winget package of a bundle with its recommended args
+type BundlePackage struct {
+	WingetID string `json:"winget_id"`
+	Name     string `json:"name,omitempty"` // defaults to winget_id
+	Args     string `json:"args,omitempty"`
+}
+
+// BundleRequest creates or replaces a bundle
+type BundleRequest struct {
+	Slug        string          `json:"slug"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Packages    []BundlePackage `json:"packages"`
+}
+
+// ScriptLink is a shareable URL that serves a generated script without a login
+type ScriptLink struct {
+	ID             int               `json:"id"`
+	URL            string            `json:"url,omitempty"` // Only known when the link is created
+	Options        map[string]string `json:"options"`
+	SingleUse      bool              `json:"single_use"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+	RevokedAt      *time.Time        `json:"revoked_at,omitempty"`
+	AccessCount    int               `json:"access_count"`
+	LastAccessedAt *time.Time        `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Active         bool              `json:"active"`
+}
+
+type CreateScriptLinkRequest struct {
+	Options    map[string]string `json:"options,omitempty"` // Same as the /api/apps/script query parameters
+	TTLMinutes int               `json:"ttl_minutes,omitempty"`
+	SingleUse  bool              `json:"single_use,omitempty"`
+}
+
+// InstallRun is one execution of a generated script that reports its results
+type InstallRun struct {
+	ID             int               `json:"id"`
+	Options        map[string]string `json:"options"`
+	Hostname       string            `json:"hostname,omitempty"`
+	OSBuild        string            `json:"os_build,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	LastReportedAt *time.Time        `json:"last_reported_at,omitempty"`
+	Succeeded      int               `json:"succeeded"`
+	Failed         int               `json:"failed"`
+	Skipped        int               `json:"skipped"`
+	Results        []InstallResult   `json:"results,omitempty"` // Only included for a single run
+}
+
+// InstallResult is the outcome of one app within a run
+type InstallResult struct {
+	ID         int       `json:"id"`
+	AppID      *int      `json:"app_id,omitempty"` // Unset once the app is deleted
+	AppName    string    `json:"app_name"`
+	Result     string    `json:"result"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// ReportResultRequest is posted by a running script for each app it processed
+type ReportResultRequest struct {
+	AppID      int    `json:"app_id"`
+	AppName    string `json:"app_name"`
+	Result     string `json:"result"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
+	OSBuild    string `json:"os_build,omitempty"`
+}
+
+// AppRunStats summarizes how an app fared across the user's reported runs
+type AppRunStats struct {
+	AppID       *int    `json:"app_id,omitempty"`
+	AppName     string  `json:"app_name"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+}
+
+// RunHistory is the response of GET /api/runs
+type RunHistory struct {
+	Runs []InstallRun  `json:"runs"`
+	Apps []AppRunStats `json:"apps"`
+}
+
+// ImportIssue describes an entry that was not imported and why
+type ImportIssue struct {
+	Entry  string `json:"entry"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult summarizes a bulk import into the apps table
+type ImportResult struct {
+	Created []App         `json:"created"`
+	Skipped []ImportIssue `json:"skipped"`
+	Invalid []ImportIssue `json:"invalid"`
 }
 
 type ErrorResponse struct {
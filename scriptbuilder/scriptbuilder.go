@@ -0,0 +1,213 @@
+// Package scriptbuilder assembles the install scripts SetupForMe generates.
+// Every value that comes from a user is escaped for the context it lands in,
+// so an app name or installer argument can never end a string literal or a
+// comment early and run as code.
+package scriptbuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Dialect is the scripting language a Builder writes
+type Dialect int
+
+const (
+	// PowerShell scripts (Windows PowerShell 5.1 and PowerShell 7)
+	PowerShell Dialect = iota
+	// POSIX shell scripts (bash, including the bash 3.2 of macOS)
+	POSIX
+)
+
+// Code is trusted script text, such as a call rendered earlier by the same
+// Builder, that Line inserts unchanged
+type Code string
+
+// Builder collects the lines of a script
+type Builder struct {
+	dialect Dialect
+	lines   []string
+}
+
+// New returns an empty Builder for the dialect
+func New(dialect Dialect) *Builder {
+	return &Builder{dialect: dialect}
+}
+
+// Line appends one line of code. format is trusted script text; its %s verbs
+// are filled with args escaped by Quote.
+func (b *Builder) Line(format string, args ...interface{}) {
+	b.lines = append(b.lines, string(b.Format(format, args...)))
+}
+
+// Format renders format like Line does without appending it
+func (b *Builder) Format(format string, args ...interface{}) Code {
+	quoted := make([]interface{}, len(args))
+	for i, arg := range args {
+		quoted[i] = string(b.Quote(arg))
+	}
+	return Code(fmt.Sprintf(format, quoted...))
+}
+
+// Comment appends a comment holding text on a single line
+func (b *Builder) Comment(text string) {
+	b.lines = append(b.lines, "# "+CommentText(text))
+}
+
+// Raw appends trusted lines unchanged, e.g. helper function definitions
+func (b *Builder) Raw(lines ...string) {
+	b.lines = append(b.lines, lines...)
+}
+
+// Blank appends an empty line
+func (b *Builder) Blank() {
+	b.lines = append(b.lines, "")
+}
+
+// Lines returns the lines collected so far
+func (b *Builder) Lines() []string {
+	return b.lines
+}
+
+// String returns the script
+func (b *Builder) String() string {
+	return strings.Join(b.lines, "\n")
+}
+
+// Quote renders a value as a literal of the dialect:
+//   - string: a single-quoted string
+//   - []string: a PowerShell array, or space-separated words in POSIX shell
+//   - int, int64 and []int: decimal numbers, []int like []string
+//   - bool: $true / $false in PowerShell, true / false in POSIX shell
+//   - Code: unchanged
+//
+// Any other type is a programming error and panics.
+func (b *Builder) Quote(v interface{}) Code {
+	switch v := v.(type) {
+	case Code:
+		return v
+	case string:
+		if b.dialect == PowerShell {
+			return Code(PSString(v))
+		}
+		return Code(ShString(v))
+	case int:
+		return Code(strconv.Itoa(v))
+	case int64:
+		return Code(strconv.FormatInt(v, 10))
+	case bool:
+		if b.dialect == PowerShell {
+			return Code("$" + strconv.FormatBool(v))
+		}
+		return Code(strconv.FormatBool(v))
+	case []string:
+		if b.dialect == PowerShell {
+			return Code(PSArray(v))
+		}
+		words := make([]string, len(v))
+		for i, s := range v {
+			words[i] = ShString(s)
+		}
+		return Code(strings.Join(words, " "))
+	case []int:
+		items := make([]string, len(v))
+		for i, n := range v {
+			items[i] = strconv.Itoa(n)
+		}
+		if b.dialect == PowerShell {
+			return Code("@(" + strings.Join(items, ", ") + ")")
+		}
+		return Code(strings.Join(items, " "))
+	}
+	panic(fmt.Sprintf("scriptbuilder: cannot quote a %T", v))
+}
+
+// psSingleQuotes are the characters PowerShell accepts as a single quote;
+// besides the ASCII one, it also ends strings at typographic quotes
+const psSingleQuotes = "'\u2018\u2019\u201A\u201B"
+
+// PSString wraps s in a PowerShell single-quoted string, in which nothing is
+// expanded. Every quote character is doubled so it stays part of the string.
+func PSString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for _, r := range stripNUL(s) {
+		if strings.ContainsRune(psSingleQuotes, r) {
+			sb.WriteRune(r)
+		}
+		sb.WriteRune(r)
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
+
+// PSArray renders items as a PowerShell array of single-quoted strings
+func PSArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = PSString(item)
+	}
+	return "@(" + strings.Join(quoted, ", ") + ")"
+}
+
+// ShString wraps s in a POSIX shell single-quoted string. A single quote in
+// s ends the string, is written as an escaped quote and starts a new one.
+func ShString(s string) string {
+	return "'" + strings.ReplaceAll(stripNUL(s), "'", `'\''`) + "'"
+}
+
+// CommentText flattens s for a line comment: line breaks and other control
+// characters become spaces, so the text cannot continue onto a line of code
+func CommentText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '\u2028' || r == '\u2029' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// stripNUL drops NUL characters, which shells cannot hold in a string
+func stripNUL(s string) string {
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// SplitArgs splits an installer argument string into arguments the way the
+// Windows C runtime does: whitespace separates arguments, double quotes group
+// text with spaces, and \" is a literal quote. The quotes are removed, so
+// INSTALLDIR="C:\Program Files\App" becomes INSTALLDIR=C:\Program Files\App;
+// the generated script quotes each argument again when it starts the process.
+func SplitArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	inArg, quoted := false, false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == '"':
+			current.WriteRune('"')
+			inArg = true
+			i++
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case (r == ' ' || r == '\t' || r == '\n' || r == '\r') && !quoted:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
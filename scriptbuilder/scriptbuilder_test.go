@@ -0,0 +1,173 @@
+package scriptbuilder
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// seeds are values that broke out of the quoting this package replaced
+var seeds = []string{
+	"",
+	"Git's",
+	"'; Remove-Item -Recurse C:\\; '",
+	"\u2019; Start-Process calc; \u2018",
+	"$(Start-Process calc)",
+	"\"quoted\" `backtick`",
+	"line one\nRemove-Item C:\\",
+	"a\r\nb\u2028c\u0085d",
+	"'\\''",
+	"nul\x00byte",
+	`/S /D="C:\Program Files\App" INSTALLDIR="x y"`,
+	`\"escaped\" "unterminated`,
+}
+
+// lexPSString reads the PowerShell single-quoted string at the start of s and
+// returns its value and the rest of s after the closing quote
+func lexPSString(t *testing.T, s string) (string, string) {
+	t.Helper()
+	r, size := utf8.DecodeRuneInString(s)
+	if !strings.ContainsRune(psSingleQuotes, r) {
+		t.Fatalf("%q does not start with a single quote", s)
+	}
+	s = s[size:]
+
+	var value strings.Builder
+	for s != "" {
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		if !strings.ContainsRune(psSingleQuotes, r) {
+			value.WriteRune(r)
+			continue
+		}
+		next, nextSize := utf8.DecodeRuneInString(s)
+		if s == "" || !strings.ContainsRune(psSingleQuotes, next) {
+			return value.String(), s
+		}
+		value.WriteRune(r)
+		s = s[nextSize:]
+	}
+	t.Fatalf("unterminated string")
+	return "", ""
+}
+
+// lexShWord reads a POSIX shell word made of single-quoted strings and
+// backslash-escaped characters and returns its value
+func lexShWord(t *testing.T, s string) string {
+	t.Helper()
+	var value strings.Builder
+	for s != "" {
+		switch s[0] {
+		case '\'':
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				t.Fatalf("unterminated string in %q", s)
+			}
+			value.WriteString(s[1 : end+1])
+			s = s[end+2:]
+		case '\\':
+			if len(s) < 2 {
+				t.Fatalf("dangling backslash")
+			}
+			value.WriteByte(s[1])
+			s = s[2:]
+		default:
+			t.Fatalf("unquoted %q outside a string", s[0])
+		}
+	}
+	return value.String()
+}
+
+func FuzzPSString(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip()
+		}
+		value, rest := lexPSString(t, PSString(s))
+		if rest != "" {
+			t.Fatalf("PSString(%q) ends early, leaving %q", s, rest)
+		}
+		if value != stripNUL(s) {
+			t.Fatalf("PSString(%q) reads back as %q", s, value)
+		}
+	})
+}
+
+func FuzzShString(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if value := lexShWord(t, ShString(s)); value != stripNUL(s) {
+			t.Fatalf("ShString(%q) reads back as %q", s, value)
+		}
+	})
+}
+
+func FuzzCommentText(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if c := CommentText(s); strings.ContainsAny(c, "\r\n\u0085\u2028\u2029") {
+			t.Fatalf("CommentText(%q) = %q spans several lines", s, c)
+		}
+	})
+}
+
+// FuzzPSArray checks that installer args stay one array element per argument
+func FuzzPSArray(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip()
+		}
+		args := SplitArgs(s)
+		rest := PSArray(args)
+		if !strings.HasPrefix(rest, "@(") {
+			t.Fatalf("PSArray(%q) = %q", args, rest)
+		}
+		rest = rest[2:]
+
+		for i, want := range args {
+			if i > 0 {
+				if !strings.HasPrefix(rest, ", ") {
+					t.Fatalf("missing separator before element %d in %q", i, rest)
+				}
+				rest = rest[2:]
+			}
+			var value string
+			value, rest = lexPSString(t, rest)
+			if value != stripNUL(want) {
+				t.Fatalf("element %d reads back as %q, want %q", i, value, want)
+			}
+		}
+		if rest != ")" {
+			t.Fatalf("PSArray(%q) has %q after the last element", args, rest)
+		}
+	})
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  /S  ", []string{"/S"}},
+		{`/VERYSILENT /DIR="C:\Program Files\App"`, []string{"/VERYSILENT", `/DIR=C:\Program Files\App`}},
+		{`--override "/quiet ALLUSERS=1"`, []string{"--override", "/quiet ALLUSERS=1"}},
+		{`say \"hi\" ""`, []string{"say", `"hi"`, ""}},
+	}
+	for _, tt := range tests {
+		got := SplitArgs(tt.in)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lifetimes of cached lookups. A fresh entry is served as is; a stale one is
+// still served but refreshed in the background. "Not found" answers are
+// cached for a shorter time so that new packages show up soon.
+const (
+	cacheFreshTTL    = 10 * time.Minute
+	cacheStaleTTL    = time.Hour
+	cacheNegativeTTL = 2 * time.Minute
+	cacheSize        = 2000 // entries per kind of lookup
+)
+
+// CacheStats counts how lookups were answered since a CachedSource was created
+type CacheStats struct {
+	Hits           int64 // served from a fresh entry
+	StaleHits      int64 // served from a stale entry while it is refreshed
+	NegativeHits   int64 // served from a cached "not found"
+	Misses         int64 // had to wait for the source
+	Coalesced      int64 // misses that joined a request already in flight
+	UpstreamErrors int64 // source lookups that failed
+	Entries        int   // responses currently cached
+}
+
+type cacheCounters struct {
+	hits, staleHits, negativeHits, misses, coalesced, upstreamErrors atomic.Int64
+}
+
+// CachedSource caches the lookups of another PackageSource and makes sure
+// only one lookup per query is in flight at a time. Failed lookups are not
+// cached, so stale entries keep being served while the source is down.
+type CachedSource struct {
+	source   PackageSource
+	counters cacheCounters
+	search   *ttlCache[[]WingetPackage]
+	resolve  *ttlCache[string]
+	details  *ttlCache[WingetPackage]
+}
+
+func NewCachedSource(source PackageSource) *CachedSource {
+	c := &CachedSource{source: source}
+	c.search = newTTLCache[[]WingetPackage](&c.counters)
+	c.resolve = newTTLCache[string](&c.counters)
+	c.details = newTTLCache[WingetPackage](&c.counters)
+	return c
+}
+
+func (c *CachedSource) Search(query string, take int) ([]WingetPackage, error) {
+	key := strconv.Itoa(take) + ":" + strings.ToLower(strings.TrimSpace(query))
+	packages, err := c.search.get(key, func() ([]WingetPackage, error) {
+		return c.source.Search(query, take)
+	})
+	// Callers may reorder the results, so they get their own slice
+	return append([]WingetPackage(nil), packages...), err
+}
+
+func (c *CachedSource) Resolve(name string) (string, error) {
+	return c.resolve.get(strings.ToLower(strings.TrimSpace(name)), func() (string, error) {
+		return c.source.Resolve(name)
+	})
+}
+
+func (c *CachedSource) Details(id string) (WingetPackage, error) {
+	pkg, err := c.details.get(strings.ToLower(id), func() (WingetPackage, error) {
+		return c.source.Details(id)
+	})
+	pkg.Versions = append([]string(nil), pkg.Versions...)
+	return pkg, err
+}
+
+// Stats returns the cache counters
+func (c *CachedSource) Stats() CacheStats {
+	return CacheStats{
+		Hits:           c.counters.hits.Load(),
+		StaleHits:      c.counters.staleHits.Load(),
+		NegativeHits:   c.counters.negativeHits.Load(),
+		Misses:         c.counters.misses.Load(),
+		Coalesced:      c.counters.coalesced.Load(),
+		UpstreamErrors: c.counters.upstreamErrors.Load(),
+		Entries:        c.search.len() + c.resolve.len() + c.details.len(),
+	}
+}
+
+type cacheEntry[V any] struct {
+	value     V
+	err       error // ErrPackageNotFound for a cached "not found"
+	fetchedAt time.Time
+}
+
+// cacheCall is an upstream request that concurrent lookups of the same key wait on
+type cacheCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// ttlCache caches upstream responses by key and makes sure only one request
+// per key is in flight at a time
+type ttlCache[V any] struct {
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry[V]
+	calls    map[string]*cacheCall[V]
+	counters *cacheCounters
+}
+
+func newTTLCache[V any](counters *cacheCounters) *ttlCache[V] {
+	return &ttlCache[V]{
+		entries:  map[string]*cacheEntry[V]{},
+		calls:    map[string]*cacheCall[V]{},
+		counters: counters,
+	}
+}
+
+func (c *ttlCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// get returns the cached response for key, calling fetch when there is none.
+// Cached values are shared, so callers must not modify them.
+func (c *ttlCache[V]) get(key string, fetch func() (V, error)) (V, error) {
+	c.mu.Lock()
+	entry := c.entries[key]
+	c.mu.Unlock()
+
+	if entry != nil {
+		age := time.Since(entry.fetchedAt)
+		switch {
+		case entry.err != nil && age < cacheNegativeTTL:
+			c.counters.negativeHits.Add(1)
+			return entry.value, entry.err
+		case entry.err == nil && age < cacheFreshTTL:
+			c.counters.hits.Add(1)
+			return entry.value, nil
+		case entry.err == nil && age < cacheStaleTTL:
+			c.counters.staleHits.Add(1)
+			c.call(key, fetch)
+			return entry.value, nil
+		}
+	}
+
+	c.counters.misses.Add(1)
+	call, joined := c.call(key, fetch)
+	if joined {
+		c.counters.coalesced.Add(1)
+	}
+	<-call.done
+	return call.value, call.err
+}
+
+// call returns the request in flight for key, starting one if there is none.
+// joined reports whether an existing request was reused.
+func (c *ttlCache[V]) call(key string, fetch func() (V, error)) (call *cacheCall[V], joined bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if call, ok := c.calls[key]; ok {
+		return call, true
+	}
+	call = &cacheCall[V]{done: make(chan struct{})}
+	c.calls[key] = call
+	go c.run(key, call, fetch)
+	return call, false
+}
+
+// run performs an upstream request and caches its response unless it failed
+func (c *ttlCache[V]) run(key string, call *cacheCall[V], fetch func() (V, error)) {
+	call.value, call.err = fetch()
+
+	c.mu.Lock()
+	if call.err == nil || errors.Is(call.err, ErrPackageNotFound) {
+		c.store(key, &cacheEntry[V]{value: call.value, err: call.err, fetchedAt: time.Now()})
+	} else {
+		c.counters.upstreamErrors.Add(1)
+	}
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// store adds an entry, making room by dropping expired entries first and
+// arbitrary ones if the cache is still full. c.mu must be held.
+func (c *ttlCache[V]) store(key string, entry *cacheEntry[V]) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= cacheSize {
+		for k, e := range c.entries {
+			age := time.Since(e.fetchedAt)
+			if age >= cacheStaleTTL || (e.err != nil && age >= cacheNegativeTTL) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < cacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
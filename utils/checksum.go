@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// MaxDownloadSize is the largest installer ComputeURLSHA256 will hash (2 GiB).
+// Bigger downloads are abandoned rather than streamed to the end.
+const MaxDownloadSize = 2 << 30
+
+// maxDownloadRedirects is how many redirects a download may follow
+const maxDownloadRedirects = 10
+
+// ErrDownloadTooLarge is returned when a download exceeds MaxDownloadSize
+var ErrDownloadTooLarge = fmt.Errorf("download is larger than %d bytes", MaxDownloadSize)
+
+// ErrNonPublicAddress is returned when a download URL, or a redirect it
+// follows, resolves to a loopback, private or link-local address
+var ErrNonPublicAddress = errors.New("download URL does not resolve to a public address")
+
+// downloadClient is used for fetching whole installers, which can be large
+var downloadClient = newDownloadClient(10 * time.Minute)
+
+// newDownloadClient returns a client for fetching user-supplied URLs. The
+// address is checked when dialing, after DNS resolution, so neither a hostname
+// nor a redirect can reach the server's own network.
+func newDownloadClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: refuseNonPublic,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: the dialer must see the real destination
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: checkDownloadRedirect,
+	}
+}
+
+func checkDownloadRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxDownloadRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxDownloadRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+	}
+	return nil
+}
+
+// refuseNonPublic is a net.Dialer Control function that only lets
+// connections to public unicast addresses through
+func refuseNonPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddr(addr) {
+		return ErrNonPublicAddress
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsValidSHA256 reports whether s is a lowercase hex-encoded SHA-256 digest
+func IsValidSHA256(s string) bool {
+	return sha256Pattern.MatchString(s)
+}
+
+// ComputeURLSHA256 downloads the file at rawURL and returns its SHA-256 digest.
+// Files larger than MaxDownloadSize are rejected with ErrDownloadTooLarge.
+func ComputeURLSHA256(rawURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrNonPublicAddress) {
+			return "", ErrNonPublicAddress
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > MaxDownloadSize {
+		return "", ErrDownloadTooLarge
+	}
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, io.LimitReader(resp.Body, MaxDownloadSize+1))
+	if err != nil {
+		return "", err
+	}
+	if n > MaxDownloadSize {
+		return "", ErrDownloadTooLarge
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
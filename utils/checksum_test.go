@@ -0,0 +1,43 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"setupforme/utils"
+)
+
+// A download_url pointing at the server's own network must not be fetched
+func TestComputeURLSHA256RefusesLoopback(t *testing.T) {
+	fetched := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		w.Write([]byte("installer"))
+	}))
+	defer srv.Close()
+
+	if _, err := utils.ComputeURLSHA256(srv.URL + "/setup.exe"); !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Errorf("ComputeURLSHA256(loopback) error = %v, want ErrNonPublicAddress", err)
+	}
+	if fetched {
+		t.Error("loopback server was contacted")
+	}
+}
+
+func TestSniffInstallerTypeRefusesLoopback(t *testing.T) {
+	fetched := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = true
+		w.Write([]byte("MZ"))
+	}))
+	defer srv.Close()
+
+	if _, err := utils.SniffInstallerType(srv.URL + "/setup.exe"); !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Errorf("SniffInstallerType(loopback) error = %v, want ErrNonPublicAddress", err)
+	}
+	if fetched {
+		t.Error("loopback server was contacted")
+	}
+}
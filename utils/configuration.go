@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DSCSchemaComment is the header winget emits to tie a document to its schema
+	DSCSchemaComment = "# yaml-language-server: $schema=https://aka.ms/configuration-dsc-schema/0.2"
+	// DSCConfigurationVersion is the configuration schema version we render and accept
+	DSCConfigurationVersion = "0.2.0"
+	// DSCWinGetPackage is the fully qualified WinGetPackage DSC resource name
+	DSCWinGetPackage = "Microsoft.WinGet.DSC/WinGetPackage"
+)
+
+// DSCConfiguration represents a WinGet Configuration document (configuration.dsc.yaml)
+type DSCConfiguration struct {
+	Properties DSCProperties `yaml:"properties"`
+}
+
+type DSCProperties struct {
+	ConfigurationVersion string        `yaml:"configurationVersion"`
+	Assertions           []DSCResource `yaml:"assertions,omitempty"`
+	Resources            []DSCResource `yaml:"resources"`
+}
+
+type DSCResource struct {
+	Resource   string             `yaml:"resource"`
+	ID         string             `yaml:"id,omitempty"`
+	DependsOn  []string           `yaml:"dependsOn,omitempty"`
+	Directives DSCDirectives      `yaml:"directives,omitempty"`
+	Settings   DSCPackageSettings `yaml:"settings"`
+}
+
+type DSCDirectives struct {
+	Description     string `yaml:"description,omitempty"`
+	AllowPrerelease bool   `yaml:"allowPrerelease,omitempty"`
+	SecurityContext string `yaml:"securityContext,omitempty"`
+	// SetupForMeArgs carries installer args, which WinGetPackage has no setting for.
+	// winget ignores directives it does not know.
+	SetupForMeArgs string `yaml:"setupForMeArgs,omitempty"`
+}
+
+type DSCPackageSettings struct {
+	ID        string `yaml:"id"`
+	Source    string `yaml:"source,omitempty"`
+	Version   string `yaml:"version,omitempty"`
+	UseLatest bool   `yaml:"useLatest,omitempty"`
+	Ensure    string `yaml:"ensure,omitempty"`
+}
+
+// DSCPackage is a package entry extracted from a validated configuration document
+type DSCPackage struct {
+	Name     string
+	WingetID string
+	Version  string
+	Args     string
+	// DependsOn holds the package IDs of the imported packages this one
+	// depends on; dependencies on resources that are not imported are dropped
+	DependsOn []string
+	// RequiresAdmin is set when the resource runs with securityContext elevated
+	RequiresAdmin bool
+}
+
+// SkippedEntry is an import entry that was not turned into a package, with the reason
+type SkippedEntry struct {
+	Entry  string
+	Reason string
+}
+
+// Package identifier pattern from the winget manifest schema
+var wingetIDPattern = regexp.MustCompile(`^[^.\s\\/:*?"<>|\x01-\x1f]{1,32}(\.[^.\s\\/:*?"<>|\x01-\x1f]{1,32}){1,7}$`)
+var msstoreIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{12}$`)
+
+// Package sources an identifier can come from
+const (
+	SourceWinget  = "winget"
+	SourceMSStore = "msstore"
+)
+
+// IsValidWingetID reports whether id is syntactically a winget or msstore package identifier
+func IsValidWingetID(id string) bool {
+	return wingetIDPattern.MatchString(id) || msstoreIDPattern.MatchString(id)
+}
+
+// IsMSStoreID reports whether id is a Microsoft Store product ID (e.g.
+// 9NBLGGH4NNS1). Winget identifiers always contain a dot, so the two never overlap.
+func IsMSStoreID(id string) bool {
+	return msstoreIDPattern.MatchString(id)
+}
+
+// SourceForID returns the source that serves a package identifier
+func SourceForID(id string) string {
+	if IsMSStoreID(id) {
+		return SourceMSStore
+	}
+	return SourceWinget
+}
+
+// prereleasePattern matches a pre-release label, with or without a leading
+// separator, as in 1.2.0-beta.1, 2.0rc1 or 3.1.0.preview. A plain hyphenated
+// build number such as 1.2.3-1 is not one.
+var prereleasePattern = regexp.MustCompile(`(?i)(^|[-._+0-9])(alpha|beta|preview|rc|insider|nightly)`)
+
+// IsPrereleaseVersion reports whether a pinned version names a pre-release build
+func IsPrereleaseVersion(v string) bool {
+	return v != "" && prereleasePattern.MatchString(v)
+}
+
+// IsValidPackageVersion reports whether v looks like a usable winget version string
+func IsValidPackageVersion(v string) bool {
+	return v != "" && len(v) <= 128 && !strings.ContainsAny(v, " \t\r\n\"'`")
+}
+
+// RenderConfiguration serializes a configuration document with the schema header
+func RenderConfiguration(cfg DSCConfiguration, comments []string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(DSCSchemaComment + "\n")
+	for _, c := range comments {
+		buf.WriteString("# " + strings.ReplaceAll(c, "\n", " ") + "\n")
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ParseConfiguration decodes and validates a configuration document.
+// It returns the WinGetPackage entries that should be present on the machine;
+// resources of other types or with ensure: Absent are reported as skipped.
+// dependsOn must name resources of the document and is resolved to the
+// package IDs of the imported packages it names.
+// A non-nil error means the document as a whole does not match the schema.
+func ParseConfiguration(data []byte) (packages []DSCPackage, skipped []SkippedEntry, problems []string, err error) {
+	var cfg DSCConfiguration
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+
+	version := cfg.Properties.ConfigurationVersion
+	if version == "" {
+		return nil, nil, nil, errors.New("properties.configurationVersion is required")
+	}
+	if version != "0.2" && !strings.HasPrefix(version, "0.2.") {
+		return nil, nil, nil, fmt.Errorf("unsupported configurationVersion %q (expected 0.2.x)", version)
+	}
+	if len(cfg.Properties.Resources) == 0 {
+		return nil, nil, nil, errors.New("properties.resources must contain at least one resource")
+	}
+
+	// Resource ids, and the package ID behind those that are imported, so
+	// that dependsOn can be resolved once every resource has been read
+	resourceIDs := make(map[string]bool)
+	packageIDs := make(map[string]string)
+	var dependsOn [][]string
+	for _, res := range cfg.Properties.Resources {
+		if res.ID != "" {
+			resourceIDs[res.ID] = true
+		}
+	}
+
+	for i, res := range cfg.Properties.Resources {
+		where := fmt.Sprintf("resources[%d]", i)
+		if res.ID != "" {
+			where += " (" + res.ID + ")"
+		}
+
+		if !strings.EqualFold(res.Resource, DSCWinGetPackage) && !strings.EqualFold(res.Resource, "WinGetPackage") {
+			if res.Resource == "" {
+				problems = append(problems, where+": resource is required")
+			} else {
+				skipped = append(skipped, SkippedEntry{Entry: where, Reason: "unsupported resource " + res.Resource})
+			}
+			continue
+		}
+
+		s := res.Settings
+		if s.Ensure != "" && !strings.EqualFold(s.Ensure, "Present") {
+			if strings.EqualFold(s.Ensure, "Absent") {
+				skipped = append(skipped, SkippedEntry{Entry: where, Reason: "ensure is Absent"})
+			} else {
+				problems = append(problems, where+": ensure must be Present or Absent")
+			}
+			continue
+		}
+
+		switch strings.ToLower(s.Source) {
+		case "", SourceWinget:
+			if !wingetIDPattern.MatchString(s.ID) {
+				problems = append(problems, where+": settings.id is not a valid winget package identifier")
+				continue
+			}
+		case SourceMSStore:
+			if !msstoreIDPattern.MatchString(s.ID) {
+				problems = append(problems, where+": settings.id is not a valid msstore product id")
+				continue
+			}
+		default:
+			problems = append(problems, where+": unsupported source "+s.Source)
+			continue
+		}
+
+		if s.Version != "" && !IsValidPackageVersion(s.Version) {
+			problems = append(problems, where+": settings.version is invalid")
+			continue
+		}
+
+		unknown := false
+		for _, dep := range res.DependsOn {
+			if !resourceIDs[dep] {
+				problems = append(problems, where+": dependsOn references unknown resource "+dep)
+				unknown = true
+			}
+		}
+		if unknown {
+			continue
+		}
+
+		name := strings.TrimSpace(res.Directives.Description)
+		if name == "" {
+			name = s.ID
+		}
+
+		if res.ID != "" {
+			packageIDs[res.ID] = s.ID
+		}
+		dependsOn = append(dependsOn, res.DependsOn)
+
+		packages = append(packages, DSCPackage{
+			Name:     name,
+			WingetID: s.ID,
+			Version:  s.Version,
+			Args:     res.Directives.SetupForMeArgs,
+
+			RequiresAdmin: strings.EqualFold(res.Directives.SecurityContext, "elevated"),
+		})
+	}
+
+	for i, deps := range dependsOn {
+		for _, dep := range deps {
+			if id, ok := packageIDs[dep]; ok {
+				packages[i].DependsOn = append(packages[i].DependsOn, id)
+			}
+		}
+	}
+
+	return packages, skipped, problems, nil
+}
@@ -0,0 +1,102 @@
+package utils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"setupforme/utils"
+)
+
+func TestIsPrereleaseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"", false},
+		{"2.44.0", false},
+		{"1.2.3-1", false},
+		{"2024-06-01", false},
+		{"1.2.0-beta.1", true},
+		{"2.0rc1", true},
+		{"3.1.0.preview", true},
+		{"1.0.0-RC", true},
+		{"124.0a1-nightly", true},
+		{"alpha", true},
+	}
+	for _, tt := range tests {
+		if got := utils.IsPrereleaseVersion(tt.version); got != tt.want {
+			t.Errorf("IsPrereleaseVersion(%q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+// An exported configuration document must import back to the same packages.
+// Dependencies on resources that are not imported are dropped.
+func TestConfigurationRoundTrip(t *testing.T) {
+	cfg := utils.DSCConfiguration{Properties: utils.DSCProperties{
+		ConfigurationVersion: utils.DSCConfigurationVersion,
+		Resources: []utils.DSCResource{
+			{
+				Resource:  utils.DSCWinGetPackage,
+				ID:        "Git.Git",
+				DependsOn: []string{"Old.Tool"},
+				Directives: utils.DSCDirectives{
+					Description:     `Git: "the" #1 tool's - [latest]`,
+					SecurityContext: "elevated",
+					SetupForMeArgs:  `--override "/VERYSILENT /COMPONENTS=icons"`,
+				},
+				Settings: utils.DSCPackageSettings{ID: "Git.Git", Source: utils.SourceWinget, Version: "2.44.0"},
+			},
+			{
+				Resource:   utils.DSCWinGetPackage,
+				ID:         "9NBLGGH4NNS1",
+				DependsOn:  []string{"Git.Git"},
+				Directives: utils.DSCDirectives{Description: "App Installer"},
+				Settings:   utils.DSCPackageSettings{ID: "9NBLGGH4NNS1", Source: utils.SourceMSStore},
+			},
+			{
+				Resource: utils.DSCWinGetPackage,
+				ID:       "Old.Tool",
+				Settings: utils.DSCPackageSettings{ID: "Old.Tool", Source: utils.SourceWinget, Ensure: "Absent"},
+			},
+		},
+	}}
+
+	data, err := utils.RenderConfiguration(cfg, []string{"Skipped Notes: no winget_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, skipped, problems, err := utils.ParseConfiguration(data)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("ParseConfiguration = %v, %v\n%s", problems, err, data)
+	}
+
+	want := []utils.DSCPackage{
+		{Name: `Git: "the" #1 tool's - [latest]`, WingetID: "Git.Git", Version: "2.44.0", Args: `--override "/VERYSILENT /COMPONENTS=icons"`, RequiresAdmin: true},
+		{Name: "App Installer", WingetID: "9NBLGGH4NNS1", DependsOn: []string{"Git.Git"}},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("packages = %+v\nwant %+v", packages, want)
+	}
+
+	if len(skipped) != 1 || skipped[0].Reason != "ensure is Absent" {
+		t.Errorf("skipped = %+v, want the Absent resource", skipped)
+	}
+}
+
+func TestParseConfigurationUnknownDependency(t *testing.T) {
+	doc := `properties:
+  configurationVersion: 0.2.0
+  resources:
+    - resource: Microsoft.WinGet.DSC/WinGetPackage
+      id: Git.Git
+      dependsOn: [Missing.Tool]
+      settings:
+        id: Git.Git
+`
+	packages, _, problems, err := utils.ParseConfiguration([]byte(doc))
+	if err != nil || len(problems) != 1 || len(packages) != 0 {
+		t.Errorf("ParseConfiguration = %+v, %v, %v; want one problem for the unknown dependency", packages, problems, err)
+	}
+}
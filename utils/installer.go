@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Installer types of download_url apps. They decide which silent switches the
+// generated script uses when an app has no args of its own.
+const (
+	InstallerMSI        = "msi"
+	InstallerNSIS       = "nsis"
+	InstallerInno       = "inno"
+	InstallerWixBurn    = "wix-burn"
+	InstallerExeUnknown = "exe-unknown"
+	InstallerMSIX       = "msix"
+	InstallerZip        = "zip"
+)
+
+// sniffSize is how much of an installer is fetched to detect its type. The
+// markers below sit in the PE headers, resources or the start of the overlay.
+const sniffSize = 1 << 20
+
+// sniffClient fetches only the start of a file, so it gives up much sooner
+// than downloadClient. It has the same address and redirect restrictions.
+var sniffClient = newDownloadClient(time.Minute)
+
+// IsValidInstallerType reports whether t is one of the known installer types
+func IsValidInstallerType(t string) bool {
+	switch t {
+	case InstallerMSI, InstallerNSIS, InstallerInno, InstallerWixBurn, InstallerExeUnknown, InstallerMSIX, InstallerZip:
+		return true
+	}
+	return false
+}
+
+// InstallerTypeFromURL infers the installer type from the file extension of
+// rawURL. An .exe could be any installer framework, so it is exe-unknown.
+// It returns "" when the extension is not recognized.
+func InstallerTypeFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".msi":
+		return InstallerMSI
+	case ".msix", ".msixbundle", ".appx", ".appxbundle":
+		return InstallerMSIX
+	case ".zip":
+		return InstallerZip
+	case ".exe":
+		return InstallerExeUnknown
+	}
+	return ""
+}
+
+// SniffInstallerType downloads the start of the file at rawURL and detects
+// its installer type from the file's contents. At most sniffSize bytes are
+// read, even when the server ignores the Range header.
+func SniffInstallerType(rawURL string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffSize-1))
+
+	resp, err := sniffClient.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrNonPublicAddress) {
+			return "", ErrNonPublicAddress
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Servers that ignore Range answer 200 with the whole file; only read the start
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	head, err := io.ReadAll(io.LimitReader(resp.Body, sniffSize))
+	if err != nil {
+		return "", err
+	}
+
+	if t := DetectInstallerType(head); t != "" {
+		return t, nil
+	}
+	return "", errors.New("unrecognized installer format")
+}
+
+// DetectInstallerType identifies an installer from the first bytes of the file.
+// It returns "" when the data is not a format we can install.
+func DetectInstallerType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}):
+		// OLE compound document, the container format of MSI packages
+		return InstallerMSI
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		if bytes.Contains(head, []byte("AppxManifest.xml")) || bytes.Contains(head, []byte("AppxMetadata/")) {
+			return InstallerMSIX
+		}
+		return InstallerZip
+	case bytes.HasPrefix(head, []byte("MZ")):
+		switch {
+		case bytes.Contains(head, []byte(".wixburn")):
+			// WiX Burn bundles add a section with this name
+			return InstallerWixBurn
+		case bytes.Contains(head, []byte("Inno Setup")):
+			return InstallerInno
+		case bytes.Contains(head, []byte("NullsoftInst")), bytes.Contains(head, []byte("Nullsoft.NSIS")):
+			return InstallerNSIS
+		}
+		return InstallerExeUnknown
+	}
+	return ""
+}
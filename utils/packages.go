@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WingetPackagesSchema is the schema URL written by `winget export`
+const WingetPackagesSchema = "https://aka.ms/winget-packages.schema.2.0.json"
+
+// WingetPackagesFile represents the packages.json document used by
+// `winget export` and `winget import`
+type WingetPackagesFile struct {
+	Schema        string                 `json:"$schema"`
+	CreationDate  string                 `json:"CreationDate"`
+	Sources       []WingetPackagesSource `json:"Sources"`
+	WinGetVersion string                 `json:"WinGetVersion,omitempty"`
+}
+
+type WingetPackagesSource struct {
+	Packages      []WingetPackagesEntry `json:"Packages"`
+	SourceDetails WingetSourceDetails   `json:"SourceDetails"`
+}
+
+type WingetPackagesEntry struct {
+	PackageIdentifier string `json:"PackageIdentifier"`
+	Version           string `json:"Version,omitempty"`
+}
+
+type WingetSourceDetails struct {
+	Argument   string `json:"Argument"`
+	Identifier string `json:"Identifier"`
+	Name       string `json:"Name"`
+	Type       string `json:"Type"`
+}
+
+// DefaultWingetSource describes the community winget source
+var DefaultWingetSource = WingetSourceDetails{
+	Argument:   "https://cdn.winget.microsoft.com/cache",
+	Identifier: "Microsoft.Winget.Source_8wekyb3d8bbwe",
+	Name:       "winget",
+	Type:       "Microsoft.PreIndexed.Package",
+}
+
+// MSStoreSource describes the Microsoft Store source
+var MSStoreSource = WingetSourceDetails{
+	Argument:   "https://storeedgefd.dsx.mp.microsoft.com/v9.0",
+	Identifier: "StoreEdgeFD",
+	Name:       "msstore",
+	Type:       "Microsoft.Rest",
+}
+
+// NewWingetPackagesFile builds a packages.json document, listing each entry
+// under the source its identifier belongs to. The winget source is always
+// present, so an empty export is still a valid document.
+func NewWingetPackagesFile(entries []WingetPackagesEntry) WingetPackagesFile {
+	winget := WingetPackagesSource{Packages: []WingetPackagesEntry{}, SourceDetails: DefaultWingetSource}
+	store := WingetPackagesSource{Packages: []WingetPackagesEntry{}, SourceDetails: MSStoreSource}
+	for _, entry := range entries {
+		if SourceForID(entry.PackageIdentifier) == SourceMSStore {
+			store.Packages = append(store.Packages, entry)
+		} else {
+			winget.Packages = append(winget.Packages, entry)
+		}
+	}
+
+	sources := []WingetPackagesSource{winget}
+	if len(store.Packages) > 0 {
+		sources = append(sources, store)
+	}
+
+	return WingetPackagesFile{
+		Schema:       WingetPackagesSchema,
+		CreationDate: time.Now().Format("2006-01-02T15:04:05.000-07:00"),
+		Sources:      sources,
+	}
+}
+
+// ParseWingetPackages decodes a packages.json document. Entries from supported
+// sources are returned; entries that cannot be imported are described in invalid.
+func ParseWingetPackages(data []byte) (entries []WingetPackagesEntry, invalid []SkippedEntry, err error) {
+	var file WingetPackagesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if file.Schema != "" && !strings.HasPrefix(file.Schema, "https://aka.ms/winget-packages.schema") {
+		return nil, nil, fmt.Errorf("unsupported $schema %q", file.Schema)
+	}
+	if len(file.Sources) == 0 {
+		return nil, nil, errors.New("Sources must contain at least one source")
+	}
+
+	for i, src := range file.Sources {
+		name := strings.ToLower(src.SourceDetails.Name)
+		for j, pkg := range src.Packages {
+			where := fmt.Sprintf("Sources[%d].Packages[%d]", i, j)
+			if pkg.PackageIdentifier != "" {
+				where = pkg.PackageIdentifier
+			}
+
+			switch {
+			case name != SourceWinget && name != SourceMSStore:
+				invalid = append(invalid, SkippedEntry{Entry: where, Reason: "unsupported source " + src.SourceDetails.Name})
+			case !IsValidWingetID(pkg.PackageIdentifier):
+				invalid = append(invalid, SkippedEntry{Entry: where, Reason: "invalid PackageIdentifier"})
+			case pkg.Version != "" && !IsValidPackageVersion(pkg.Version):
+				invalid = append(invalid, SkippedEntry{Entry: where, Reason: "invalid Version"})
+			default:
+				entries = append(entries, pkg)
+			}
+		}
+	}
+
+	return entries, invalid, nil
+}
@@ -0,0 +1,56 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"setupforme/utils"
+)
+
+// winget export writes Store apps under their own source; importing such a
+// file and exporting the result must keep them there
+func TestWingetPackagesRoundTrip(t *testing.T) {
+	exported := `{
+		"$schema": "https://aka.ms/winget-packages.schema.2.0.json",
+		"Sources": [
+			{"Packages": [{"PackageIdentifier": "Git.Git", "Version": "2.44.0"}],
+			 "SourceDetails": {"Name": "winget", "Argument": "https://cdn.winget.microsoft.com/cache",
+				"Identifier": "Microsoft.Winget.Source_8wekyb3d8bbwe", "Type": "Microsoft.PreIndexed.Package"}},
+			{"Packages": [{"PackageIdentifier": "9NBLGGH4NNS1"}],
+			 "SourceDetails": {"Name": "msstore", "Argument": "https://storeedgefd.dsx.mp.microsoft.com/v9.0",
+				"Identifier": "StoreEdgeFD", "Type": "Microsoft.Rest"}}
+		]
+	}`
+
+	entries, invalid, err := utils.ParseWingetPackages([]byte(exported))
+	if err != nil || len(invalid) != 0 || len(entries) != 2 {
+		t.Fatalf("import = %v, %v, %v", entries, invalid, err)
+	}
+
+	data, err := json.Marshal(utils.NewWingetPackagesFile(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file utils.WingetPackagesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string][]string{}
+	for _, src := range file.Sources {
+		for _, pkg := range src.Packages {
+			sources[src.SourceDetails.Name] = append(sources[src.SourceDetails.Name], pkg.PackageIdentifier)
+		}
+	}
+	if got := sources["winget"]; len(got) != 1 || got[0] != "Git.Git" {
+		t.Errorf("winget source = %v, want [Git.Git]", got)
+	}
+	if got := sources["msstore"]; len(got) != 1 || got[0] != "9NBLGGH4NNS1" {
+		t.Errorf("msstore source = %v, want [9NBLGGH4NNS1]", got)
+	}
+
+	again, invalid, err := utils.ParseWingetPackages(data)
+	if err != nil || len(invalid) != 0 || len(again) != 2 {
+		t.Fatalf("re-import = %v, %v, %v", again, invalid, err)
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "errors"
+
+// ErrPackageNotFound is returned when a package source has no matching package
+var ErrPackageNotFound = errors.New("no package found")
+
+// ErrSourceUnavailable is returned without contacting a package source that
+// has been failing, until it has had time to recover
+var ErrSourceUnavailable = errors.New("package source is temporarily unavailable")
+
+// PackageSource looks up winget packages. Implementations are safe for
+// concurrent use.
+type PackageSource interface {
+	// Search returns up to take packages matching query, best match first. A
+	// search without matches returns ErrPackageNotFound.
+	Search(query string, take int) ([]WingetPackage, error)
+
+	// Resolve returns the ID of the package that best matches an app name
+	Resolve(name string) (string, error)
+
+	// Details returns a package by ID, matched case-insensitively, with all
+	// its published versions newest first
+	Details(id string) (WingetPackage, error)
+}
@@ -4,18 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// WingetRunAPI is the base URL of the public winget.run API
+const WingetRunAPI = "https://api.winget.run/v2"
+
 // WingetPackage represents a subset of winget.run API response
 type WingetPackage struct {
 	Id     string `json:"Id"`
 	Latest struct {
-		Name      string `json:"Name"`
-		Publisher string `json:"Publisher"`
+		Name        string `json:"Name"`
+		Publisher   string `json:"Publisher"`
+		Description string `json:"Description"`
+		Homepage    string `json:"Homepage"`
+		License     string `json:"License"`
 	} `json:"Latest"`
+	Versions []string `json:"Versions"`
+	IconUrl  string   `json:"IconUrl"`
 }
 
 type wingetV2Response struct {
@@ -23,44 +35,214 @@ type wingetV2Response struct {
 	Total    int             `json:"Total"`
 }
 
-var httpClient = &http.Client{Timeout: 8 * time.Second}
+type wingetV2PackageResponse struct {
+	Package WingetPackage `json:"Package"`
+}
+
+// WingetRunSource looks up packages with the winget.run API. Failed requests
+// are retried with jittered exponential backoff, and after FailureThreshold
+// lookups in a row have failed, lookups fail fast with ErrSourceUnavailable
+// for Cooldown before a single probe request is let through again.
+type WingetRunSource struct {
+	BaseURL string
+	Client  *http.Client
 
-// ResolveWingetID tries to find a winget package Id by a human-friendly app name.
-// It returns the first match's Id if found.
-func ResolveWingetID(appName string) (string, error) {
-	if appName == "" {
-		return "", errors.New("app name is empty")
+	Retries int           // extra attempts after a failed request
+	Backoff time.Duration // delay before the first retry, doubled for each one
+
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	breaker circuitBreaker
+}
+
+// NewWingetRunSource returns a source for the winget.run API at baseURL. A nil
+// client uses one with an 8 second timeout.
+func NewWingetRunSource(baseURL string, client *http.Client) *WingetRunSource {
+	if client == nil {
+		client = &http.Client{Timeout: 8 * time.Second}
 	}
-	endpoint := "https://api.winget.run/v2/packages"
-	q := url.Values{}
-	q.Set("query", appName)
-	reqURL := fmt.Sprintf("%s?%s", endpoint, q.Encode())
+	return &WingetRunSource{
+		BaseURL:          strings.TrimSuffix(baseURL, "/"),
+		Client:           client,
+		Retries:          2,
+		Backoff:          200 * time.Millisecond,
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// statusError is an unexpected HTTP status from winget.run
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("winget.run returned status %d", e.code)
+}
 
+// retryable reports whether a failed request may succeed when sent again:
+// network errors, rate limiting and server errors are, anything else is not
+func retryable(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// getJSON performs a GET against winget.run, retrying failed requests, and
+// decodes the JSON body into v
+func (s *WingetRunSource) getJSON(reqURL string, v any) error {
+	if !s.breaker.allow() {
+		return ErrSourceUnavailable
+	}
+
+	var err error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt > 0 {
+			// Equal jitter: half the backoff plus a random share of the other half
+			delay := s.Backoff << (attempt - 1)
+			time.Sleep(delay/2 + rand.N(delay/2+1))
+		}
+		if err = s.get(reqURL, v); !retryable(err) {
+			break
+		}
+	}
+
+	s.breaker.record(err == nil || errors.Is(err, ErrPackageNotFound), s.FailureThreshold, s.Cooldown)
+	return err
+}
+
+func (s *WingetRunSource) get(reqURL string, v any) error {
 	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
 	if err != nil {
-		return "", err
+		return err
 	}
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := s.Client.Do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrPackageNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("winget.run returned status %d", resp.StatusCode)
+		return statusError{resp.StatusCode}
 	}
 
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Search returns up to take packages matching query, in the order winget.run
+// ranks them. Each package's Versions are sorted newest first.
+func (s *WingetRunSource) Search(query string, take int) ([]WingetPackage, error) {
+	q := url.Values{}
+	q.Set("query", query)
+	q.Set("take", strconv.Itoa(take))
+	reqURL := fmt.Sprintf("%s/packages?%s", s.BaseURL, q.Encode())
+
 	var data wingetV2Response
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	if err := s.getJSON(reqURL, &data); err != nil {
+		return nil, err
+	}
+	if len(data.Packages) == 0 {
+		return nil, ErrPackageNotFound
+	}
+
+	for i := range data.Packages {
+		sortVersions(data.Packages[i].Versions)
+	}
+	return data.Packages, nil
+}
+
+// Resolve returns the Id of winget.run's first match for a human-friendly app name
+func (s *WingetRunSource) Resolve(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("app name is empty")
+	}
+
+	packages, err := s.Search(name, 1)
+	if err != nil {
 		return "", err
 	}
+	return packages[0].Id, nil
+}
+
+// Details returns a package with its published versions, newest first
+func (s *WingetRunSource) Details(id string) (WingetPackage, error) {
+	// winget.run addresses packages as /packages/{publisher}/{name}
+	publisher, name, ok := strings.Cut(id, ".")
+	if !ok || publisher == "" || name == "" {
+		return WingetPackage{}, ErrPackageNotFound
+	}
+	reqURL := fmt.Sprintf("%s/packages/%s/%s", s.BaseURL, url.PathEscape(publisher), url.PathEscape(name))
 
-	if len(data.Packages) == 0 {
-		return "", errors.New("no package found")
+	var data wingetV2PackageResponse
+	if err := s.getJSON(reqURL, &data); err != nil {
+		return WingetPackage{}, err
+	}
+	if !strings.EqualFold(data.Package.Id, id) {
+		return WingetPackage{}, ErrPackageNotFound
+	}
+
+	sortVersions(data.Package.Versions)
+	return data.Package, nil
+}
+
+// sortVersions orders versions newest first
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) > 0
+	})
+}
+
+// CompareVersions orders dotted version strings the way winget does for
+// common cases: numeric segments compare numerically, others lexically.
+// It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	as := strings.FieldsFunc(a, isVersionSeparator)
+	bs := strings.FieldsFunc(b, isVersionSeparator)
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+
+		xn, xerr := strconv.ParseUint(x, 10, 64)
+		yn, yerr := strconv.ParseUint(y, 10, 64)
+		switch {
+		case x == y:
+			continue
+		case xerr == nil && yerr == nil:
+			if xn < yn {
+				return -1
+			}
+			if xn > yn {
+				return 1
+			}
+		case x == "":
+			return -1
+		case y == "":
+			return 1
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
 	}
 
-	// Return the first match id
-	return data.Packages[0].Id, nil
+	return 0
+}
+
+func isVersionSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '+' || r == '_'
 }
@@ -0,0 +1,129 @@
+package utils_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"setupforme/utils"
+	"setupforme/utils/wingetfake"
+)
+
+func newServer(t *testing.T) *wingetfake.Server {
+	t.Helper()
+	srv := wingetfake.NewServer(
+		wingetfake.Package("Git.Git", "Git", "The Git Team", "2.9.0", "2.44.0"),
+		wingetfake.Package("GitHub.GitHubDesktop", "GitHub Desktop", "GitHub, Inc.", "3.3.0"),
+	)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWingetRunSourceLookups(t *testing.T) {
+	source := newServer(t).Source()
+
+	packages, err := source.Search("git", 10)
+	if err != nil || len(packages) != 2 {
+		t.Fatalf("Search = %v, %v", packages, err)
+	}
+	if id, err := source.Resolve("Git"); err != nil || id != "Git.Git" {
+		t.Fatalf("Resolve = %q, %v", id, err)
+	}
+
+	pkg, err := source.Details("git.git")
+	if err != nil || pkg.Id != "Git.Git" || pkg.Versions[0] != "2.44.0" {
+		t.Fatalf("Details = %+v, %v", pkg, err)
+	}
+
+	if _, err := source.Search("nothing", 10); !errors.Is(err, utils.ErrPackageNotFound) {
+		t.Fatalf("Search without matches = %v", err)
+	}
+	if _, err := source.Details("Nobody.Nothing"); !errors.Is(err, utils.ErrPackageNotFound) {
+		t.Fatalf("Details of a missing package = %v", err)
+	}
+}
+
+func TestWingetRunSourceRetries(t *testing.T) {
+	srv := newServer(t)
+	source := srv.Source()
+
+	srv.FailNext(2, http.StatusServiceUnavailable)
+	if _, err := source.Resolve("Git"); err != nil {
+		t.Fatalf("Resolve after two failures = %v", err)
+	}
+	if got := srv.Requests(); got != 3 {
+		t.Fatalf("sent %d requests, want 3", got)
+	}
+
+	// Client errors are not retried
+	srv.FailNext(1, http.StatusBadRequest)
+	if _, err := source.Resolve("Git"); err == nil {
+		t.Fatal("Resolve succeeded despite a 400")
+	}
+	if got := srv.Requests(); got != 4 {
+		t.Fatalf("sent %d requests, want 4", got)
+	}
+}
+
+func TestWingetRunSourceCircuitBreaker(t *testing.T) {
+	srv := newServer(t)
+	source := srv.Source()
+	source.Retries = 0
+	source.FailureThreshold = 3
+	source.Cooldown = 50 * time.Millisecond
+
+	srv.FailNext(3, http.StatusInternalServerError)
+	for i := 0; i < 3; i++ {
+		if _, err := source.Resolve("Git"); err == nil || errors.Is(err, utils.ErrSourceUnavailable) {
+			t.Fatalf("lookup %d = %v, want an upstream error", i, err)
+		}
+	}
+
+	if _, err := source.Resolve("Git"); !errors.Is(err, utils.ErrSourceUnavailable) {
+		t.Fatalf("lookup with an open breaker = %v", err)
+	}
+	if got := srv.Requests(); got != 3 {
+		t.Fatalf("sent %d requests, want 3", got)
+	}
+
+	time.Sleep(source.Cooldown)
+	if _, err := source.Resolve("Git"); err != nil {
+		t.Fatalf("probe after the cooldown = %v", err)
+	}
+	if _, err := source.Resolve("Git"); err != nil {
+		t.Fatalf("lookup after a successful probe = %v", err)
+	}
+}
+
+func TestCachedSourceCoalesces(t *testing.T) {
+	srv := newServer(t)
+	cache := utils.NewCachedSource(srv.Source())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Details("Git.Git"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := cache.Search("nothing", 10); !errors.Is(err, utils.ErrPackageNotFound) {
+		t.Fatalf("Search without matches = %v", err)
+	}
+	if _, err := cache.Search("nothing", 10); !errors.Is(err, utils.ErrPackageNotFound) {
+		t.Fatalf("cached search without matches = %v", err)
+	}
+
+	if got := srv.Requests(); got != 2 {
+		t.Fatalf("sent %d requests, want 2", got)
+	}
+	stats := cache.Stats()
+	if stats.Misses+stats.Hits != 11 || stats.NegativeHits != 1 || stats.Entries != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,123 @@
+// Package wingetfake serves a fake winget.run API from an httptest server, so
+// that code using utils.WingetRunSource can be exercised without the network:
+//
+//	srv := wingetfake.NewServer(wingetfake.Package("Git.Git", "Git", "The Git Team", "2.44.0"))
+//	defer srv.Close()
+//	source := srv.Source()
+package wingetfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"setupforme/utils"
+)
+
+// Server is a fake winget.run API holding a fixed set of packages
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	packages   []utils.WingetPackage
+	failures   int // requests left to fail
+	failStatus int
+	requests   int
+}
+
+// Package builds a package for NewServer
+func Package(id, name, publisher string, versions ...string) utils.WingetPackage {
+	pkg := utils.WingetPackage{Id: id, Versions: versions}
+	pkg.Latest.Name = name
+	pkg.Latest.Publisher = publisher
+	return pkg
+}
+
+// NewServer starts a server for the given packages. Searches match packages
+// whose ID or name contains the query, case-insensitively, in the order given.
+func NewServer(packages ...utils.WingetPackage) *Server {
+	s := &Server{packages: packages}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /packages", s.search)
+	mux.HandleFunc("GET /packages/{publisher}/{name}", s.details)
+	s.Server = httptest.NewServer(s.count(mux))
+	return s
+}
+
+// Source returns a winget.run source for the server that retries without
+// waiting, so tests of retries and the circuit breaker run quickly
+func (s *Server) Source() *utils.WingetRunSource {
+	source := utils.NewWingetRunSource(s.URL, s.Client())
+	source.Backoff = time.Millisecond
+	return source
+}
+
+// FailNext makes the next n requests fail with the given HTTP status
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures, s.failStatus = n, status
+}
+
+// Requests returns the number of requests the server has received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		fail := s.failures > 0
+		if fail {
+			s.failures--
+		}
+		status := s.failStatus
+		s.mu.Unlock()
+
+		if fail {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("query"))
+	take, err := strconv.Atoi(r.URL.Query().Get("take"))
+	if err != nil || take < 1 {
+		take = 12
+	}
+
+	matches := []utils.WingetPackage{}
+	for _, pkg := range s.packages {
+		if strings.Contains(strings.ToLower(pkg.Id), query) || strings.Contains(strings.ToLower(pkg.Latest.Name), query) {
+			matches = append(matches, pkg)
+		}
+	}
+	total := len(matches)
+	if len(matches) > take {
+		matches = matches[:take]
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"Packages": matches, "Total": total})
+}
+
+func (s *Server) details(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("publisher") + "." + r.PathValue("name")
+	for _, pkg := range s.packages {
+		if strings.EqualFold(pkg.Id, id) {
+			json.NewEncoder(w).Encode(map[string]any{"Package": pkg})
+			return
+		}
+	}
+	http.NotFound(w, r)
+}